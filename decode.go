@@ -0,0 +1,200 @@
+package regexp2
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// FindStringInto searches s for a match and decodes its groups into dst as Match.Decode
+// does.  It returns false, leaving dst unchanged, if there's no match.
+// error will be set if a timeout occurs or the groups can't be decoded
+func (re *Regexp) FindStringInto(s string, dst interface{}) (bool, error) {
+	m, err := re.FindStringMatch(s)
+	if err != nil || m == nil {
+		return false, err
+	}
+	return true, m.Decode(dst)
+}
+
+// Decode stores the groups of the match in the struct that dst points to.  A field
+// tagged `regexp2:"name"` receives the group with that name, or number for unnamed
+// groups; other fields are ignored and tags for groups that aren't in the pattern
+// are an error.
+//
+// The last capture of the group is converted to the type of the field: strings, signed
+// and unsigned integers in base 10, floats, bools as accepted by strconv.ParseBool,
+// time.Duration as accepted by time.ParseDuration, []byte, and any type whose pointer
+// implements encoding.TextUnmarshaler.  A pointer field is allocated and the value
+// stored in it.  Any other slice field receives every capture of the group, in order,
+// with each one converted to the element type.
+//
+// Fields for groups that didn't participate in the match are left unchanged.
+func (m *Match) Decode(dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return errors.New("regexp2: Decode needs a non-nil pointer to a struct")
+	}
+	v = v.Elem()
+
+	fields, err := decodeFieldsOf(v.Type())
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fields {
+		g := m.GroupByName(f.group)
+		if g == nil {
+			return fmt.Errorf("regexp2: field %v refers to group %q, which isn't in the pattern", f.name, f.group)
+		}
+		if !g.Success {
+			continue
+		}
+
+		fv := v.FieldByIndex(f.index)
+		if f.all {
+			s := reflect.MakeSlice(fv.Type(), len(g.Captures), len(g.Captures))
+			for i := range g.Captures {
+				if err := decodeText(s.Index(i), g.Captures[i].String()); err != nil {
+					return fmt.Errorf("regexp2: decoding group %q into field %v: %w", f.group, f.name, err)
+				}
+			}
+			fv.Set(s)
+		} else if err := decodeText(fv, g.String()); err != nil {
+			return fmt.Errorf("regexp2: decoding group %q into field %v: %w", f.group, f.name, err)
+		}
+	}
+	return nil
+}
+
+// decodeField is a struct field tagged with a group
+type decodeField struct {
+	index []int
+	name  string
+	group string
+	all   bool // a slice that receives every capture
+}
+
+// decodeFields caches the tagged fields of each struct type passed to Decode
+var decodeFields sync.Map // map[reflect.Type][]decodeField
+
+func decodeFieldsOf(t reflect.Type) ([]decodeField, error) {
+	if fields, ok := decodeFields.Load(t); ok {
+		return fields.([]decodeField), nil
+	}
+
+	var fields []decodeField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		group, ok := sf.Tag.Lookup("regexp2")
+		if !ok {
+			continue
+		}
+		if sf.PkgPath != "" {
+			return nil, fmt.Errorf("regexp2: tagged field %v is unexported", sf.Name)
+		}
+		if group == "" {
+			return nil, fmt.Errorf("regexp2: tag of field %v has no group name", sf.Name)
+		}
+
+		f := decodeField{index: sf.Index, name: sf.Name, group: group}
+		ft := sf.Type
+		if ft.Kind() == reflect.Slice && !isTextType(ft) {
+			f.all = true
+			ft = ft.Elem()
+		}
+		if !isTextType(ft) {
+			return nil, fmt.Errorf("regexp2: field %v has unsupported type %v", sf.Name, sf.Type)
+		}
+		fields = append(fields, f)
+	}
+
+	decodeFields.Store(t, fields)
+	return fields, nil
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// isTextType returns true if decodeText can store a capture in a value of type t
+func isTextType(t reflect.Type) bool {
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) || t == durationType {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.Uint8
+	case reflect.Ptr:
+		return isTextType(t.Elem())
+	}
+	return false
+}
+
+// decodeText converts s to the type of v and stores it there
+func decodeText(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Ptr {
+		p := reflect.New(v.Type().Elem())
+		if err := decodeText(p.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	}
+
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		v.SetBytes([]byte(s))
+	}
+	return nil
+}
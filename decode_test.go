@@ -0,0 +1,143 @@
+package regexp2
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type logLine struct {
+	Host    net.IP        `regexp2:"host"`
+	Status  int           `regexp2:"status"`
+	Size    uint32        `regexp2:"size"`
+	Elapsed time.Duration `regexp2:"elapsed"`
+	Ratio   float64       `regexp2:"ratio"`
+	Cached  bool          `regexp2:"cached"`
+	Path    string        `regexp2:"path"`
+	User    *string       `regexp2:"user"`
+	Tags    []string      `regexp2:"tag"`
+	Raw     []byte        `regexp2:"0"`
+	Ignored string
+}
+
+var logPattern = MustCompile(`^(?<host>\S+) (?:(?<user>\w+) )?(?<status>\d+) (?<size>\d+) (?<elapsed>\S+) (?<ratio>\S+) (?<cached>\w+) (?<path>\S+)(?: #(?<tag>\w+))*$`, 0)
+
+func TestDecode(t *testing.T) {
+	var l logLine
+	l.Ignored = "keep"
+	ok, err := logPattern.FindStringInto("10.0.0.1 alice 200 512 1.5ms 0.25 true /index #a #bb", &l)
+	if err != nil {
+		t.Fatalf("Unexpected err: %v", err)
+	}
+	if !ok {
+		t.Fatal("Expected a match")
+	}
+
+	user := "alice"
+	want := logLine{
+		Host:    net.ParseIP("10.0.0.1"),
+		Status:  200,
+		Size:    512,
+		Elapsed: 1500 * time.Microsecond,
+		Ratio:   0.25,
+		Cached:  true,
+		Path:    "/index",
+		User:    &user,
+		Tags:    []string{"a", "bb"},
+		Raw:     []byte("10.0.0.1 alice 200 512 1.5ms 0.25 true /index #a #bb"),
+		Ignored: "keep",
+	}
+	if !reflect.DeepEqual(want, l) {
+		t.Fatalf("Wanted '%+v'\nGot '%+v'", want, l)
+	}
+}
+
+func TestDecode_NotParticipating(t *testing.T) {
+	l := logLine{Tags: []string{"old"}}
+	ok, err := logPattern.FindStringInto("::1 404 0 2s 1 false /x", &l)
+	if err != nil {
+		t.Fatalf("Unexpected err: %v", err)
+	}
+	if !ok {
+		t.Fatal("Expected a match")
+	}
+	if l.User != nil {
+		t.Fatalf("Expected no user, got %v", *l.User)
+	}
+	if want, got := []string{"old"}, l.Tags; !reflect.DeepEqual(want, got) {
+		t.Fatalf("Wanted '%v'\nGot '%v'", want, got)
+	}
+	if want, got := 404, l.Status; want != got {
+		t.Fatalf("Wanted '%v'\nGot '%v'", want, got)
+	}
+}
+
+func TestDecode_NoMatch(t *testing.T) {
+	l := logLine{Path: "keep"}
+	ok, err := logPattern.FindStringInto("nope", &l)
+	if err != nil || ok {
+		t.Fatalf("Wanted no match, got %v %v", ok, err)
+	}
+	if l.Path != "keep" {
+		t.Fatalf("Expected dst to be unchanged, got %+v", l)
+	}
+}
+
+func TestDecode_SliceConversion(t *testing.T) {
+	var dst struct {
+		Nums []int8          `regexp2:"1"`
+		Durs []time.Duration `regexp2:"d"`
+	}
+	re := MustCompile(`(?:(\d+),)+(?:(?<d>\w+);)+`, 0)
+	if _, err := re.FindStringInto("1,22,100,1s;2m;", &dst); err != nil {
+		t.Fatalf("Unexpected err: %v", err)
+	}
+	if want, got := []int8{1, 22, 100}, dst.Nums; !reflect.DeepEqual(want, got) {
+		t.Fatalf("Wanted '%v'\nGot '%v'", want, got)
+	}
+	if want, got := []time.Duration{time.Second, 2 * time.Minute}, dst.Durs; !reflect.DeepEqual(want, got) {
+		t.Fatalf("Wanted '%v'\nGot '%v'", want, got)
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	re := MustCompile(`(?<n>\w+)`, 0)
+	m, err := re.FindStringMatch("300")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var small struct {
+		N int8 `regexp2:"n"`
+	}
+	var missing struct {
+		N string `regexp2:"x"`
+	}
+	var unsupported struct {
+		N map[string]int `regexp2:"n"`
+	}
+	var unexported struct {
+		n string `regexp2:"n"`
+	}
+	var i int
+
+	for _, tt := range []struct {
+		dst  interface{}
+		want string
+	}{
+		{&small, `decoding group "n" into field N`},
+		{&missing, `group "x", which isn't in the pattern`},
+		{&unsupported, "unsupported type"},
+		{&unexported, "unexported"},
+		{unexported, "pointer to a struct"},
+		{&i, "pointer to a struct"},
+		{nil, "pointer to a struct"},
+	} {
+		err := m.Decode(tt.dst)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Decode %T: Wanted error containing '%v'\nGot '%v'", tt.dst, tt.want, err)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package regexp2
+
+import (
+	"errors"
+
+	"github.com/dlclark/regexp2/syntax"
+)
+
+// Template is a substitution pattern compiled for a Regexp, using the same syntax as
+// the replacement text of Replace: $1, ${name}, $&, $`, $', $+, $_ and $$.
+// A Template is safe for concurrent use by multiple goroutines.
+type Template struct {
+	re   *Regexp
+	data *syntax.ReplacerData
+}
+
+// CompileTemplate parses a substitution pattern so it can be expanded for many matches of re
+func (re *Regexp) CompileTemplate(template string) (*Template, error) {
+	data, err := re.replacerData(template, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{re: re, data: data}, nil
+}
+
+// String returns the source text of the template
+func (t *Template) String() string {
+	return t.data.Rep
+}
+
+// Expand returns the template with its substitutions filled in from m.
+// m must be a match of the Regexp the template was compiled for.
+func (t *Template) Expand(m *Match) string {
+	rw := &runeWriter{}
+	t.expand(rw, m)
+	return string(rw.buf)
+}
+
+// AppendExpand appends the template, with its substitutions filled in from m, to dst
+// and returns the result.  m must be a match of the Regexp the template was compiled for.
+func (t *Template) AppendExpand(dst []byte, m *Match) []byte {
+	rw := &runeWriter{buf: dst}
+	t.expand(rw, m)
+	return rw.buf
+}
+
+func (t *Template) expand(rw *runeWriter, m *Match) {
+	if m.regex != t.re {
+		panic("regexp2: Template expanded with a match of another Regexp")
+	}
+	replacementImpl(t.data, rw, m)
+}
+
+// Expand returns template with its substitutions filled in from the match, using the
+// syntax of the replacement text of Replace.  Use CompileTemplate to parse a template
+// once for many matches.
+func (m *Match) Expand(template string) (string, error) {
+	t, err := m.regex.CompileTemplate(template)
+	if err != nil {
+		return "", err
+	}
+	return t.Expand(m), nil
+}
+
+// ExpandString appends template to dst, with its substitutions filled in from match,
+// and returns the result.  src is the string that match was found in, as with the
+// ExpandString method of the regexp package, and the captured text is read from it.
+// The template uses the syntax of the replacement text of Replace.
+func (re *Regexp) ExpandString(dst []byte, template string, src string, match *Match) ([]byte, error) {
+	if match == nil {
+		return nil, errors.New("match must not be nil")
+	}
+	if match.regex != re {
+		return nil, errors.New("match must be from this Regexp")
+	}
+	text := getRunes(src)
+	if len(text) != match.textLen() {
+		return nil, errors.New("match must be found in src")
+	}
+	t, err := re.CompileTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+
+	// the match's positions are rune offsets, so they index the runes of src
+	m := *match
+	m.text, m.input = text, nil
+	return t.AppendExpand(dst, &m), nil
+}
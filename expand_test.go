@@ -0,0 +1,105 @@
+package regexp2
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTemplate_Expand(t *testing.T) {
+	re := MustCompile(`(?<key>\w+)=(\w+)`, 0)
+	tmpl, err := re.CompileTemplate("${key}: $1 [$&] <$`|$'> $+ $$")
+	if err != nil {
+		t.Fatalf("Unexpected err: %v", err)
+	}
+
+	var got []string
+	for m, _ := re.FindStringMatch("a=1, bb=22"); m != nil; m, _ = re.FindNextMatch(m) {
+		got = append(got, tmpl.Expand(m))
+	}
+	// $+ is the highest numbered group, and named groups are numbered last
+	want := "a: 1 [a=1] <|, bb=22> a $\nbb: 22 [bb=22] <a=1, |> bb $"
+	if strings.Join(got, "\n") != want {
+		t.Fatalf("Wanted '%v'\nGot '%v'", want, strings.Join(got, "\n"))
+	}
+}
+
+func TestTemplate_AppendExpand(t *testing.T) {
+	re := MustCompile(`(\d+)`, 0)
+	tmpl, err := re.CompileTemplate("<$1>")
+	if err != nil {
+		t.Fatalf("Unexpected err: %v", err)
+	}
+	m, _ := re.FindStringMatch("x42")
+	if want, got := "pre<42>", string(tmpl.AppendExpand([]byte("pre"), m)); want != got {
+		t.Fatalf("Wanted '%v'\nGot '%v'", want, got)
+	}
+	if want, got := "<$1>", tmpl.String(); want != got {
+		t.Fatalf("Wanted '%v'\nGot '%v'", want, got)
+	}
+}
+
+func TestTemplate_Input(t *testing.T) {
+	re := MustCompile(`b+`, 0)
+	in := newRopeInput("aabbcc", 3)
+	m, err := re.FindInputMatch(in)
+	if err != nil || m == nil {
+		t.Fatalf("Unexpected result: %v %v", m, err)
+	}
+	if want, got := "[aa|bb|cc|aabbcc]", mustExpand(t, m, "[$`|$&|$'|$_]"); want != got {
+		t.Fatalf("Wanted '%v'\nGot '%v'", want, got)
+	}
+}
+
+func mustExpand(t *testing.T, m *Match, template string) string {
+	t.Helper()
+	s, err := m.Expand(template)
+	if err != nil {
+		t.Fatalf("Unexpected err: %v", err)
+	}
+	return s
+}
+
+func TestMatch_Expand(t *testing.T) {
+	re := MustCompile(`(?<first>\w+)\s(?<last>\w+)`, 0)
+	m, _ := re.FindStringMatch("Ada Lovelace")
+	if want, got := "Lovelace, Ada", mustExpand(t, m, "${last}, ${first}"); want != got {
+		t.Fatalf("Wanted '%v'\nGot '%v'", want, got)
+	}
+	if _, err := m.Expand("${first"); err != nil {
+		t.Fatalf("Unexpected err: %v", err)
+	}
+}
+
+func TestRegexp_ExpandString(t *testing.T) {
+	re := MustCompile(`(\w)(\w)`, 0)
+	src := "ab"
+	m, _ := re.FindStringMatch(src)
+
+	b, err := re.ExpandString([]byte("x"), "$2$1", src, m)
+	if err != nil {
+		t.Fatalf("Unexpected err: %v", err)
+	}
+	if want, got := "xba", string(b); want != got {
+		t.Fatalf("Wanted '%v'\nGot '%v'", want, got)
+	}
+
+	// the text comes from src, whose runes line up with the match
+	b, err = re.ExpandString(nil, "$2$1", "çd", m)
+	if err != nil {
+		t.Fatalf("Unexpected err: %v", err)
+	}
+	if want, got := "dç", string(b); want != got {
+		t.Fatalf("Wanted '%v'\nGot '%v'", want, got)
+	}
+	if _, err := re.ExpandString(nil, "$1", "a", m); err == nil {
+		t.Fatal("Expected error for a src shorter than the match's text")
+	}
+
+	other := MustCompile(`(\w)(\w)`, 0)
+	if _, err := other.ExpandString(nil, "$1", src, m); err == nil {
+		t.Fatal("Expected error for a match of another Regexp")
+	}
+	if _, err := re.ExpandString(nil, "$1", src, nil); err == nil {
+		t.Fatal("Expected error for a nil match")
+	}
+}
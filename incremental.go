@@ -0,0 +1,165 @@
+package regexp2
+
+import "github.com/dlclark/regexp2/syntax"
+
+// MatchState is the outcome of feeding input to an IncrementalMatcher
+type MatchState int
+
+const (
+	// NeedMoreInput means the input seen so far may not decide whether there's a match.
+	// It's reported when an attempt needs to look past the end of the input.
+	NeedMoreInput MatchState = iota
+	// Matched means a match was found that more input can't change
+	Matched
+	// NoMatch means no match is possible whatever input follows
+	NoMatch
+)
+
+func (s MatchState) String() string {
+	switch s {
+	case NeedMoreInput:
+		return "NeedMoreInput"
+	case Matched:
+		return "Matched"
+	case NoMatch:
+		return "NoMatch"
+	}
+	return "MatchState(?)"
+}
+
+// IncrementalMatcher looks for the first match of a Regexp in input that arrives in
+// fragments.  After each fragment it reports whether the input decides the outcome.
+//
+// The search isn't restarted from the beginning of the input each time: an attempt
+// that reaches the end of the input is suspended there, keeping its backtracking
+// state, and continues from the same point when the next fragment arrives.  Starting
+// positions whose attempts failed are never retried.  RightToLeft patterns can't be
+// decided before Close.  An IncrementalMatcher is not safe for concurrent use.
+type IncrementalMatcher struct {
+	re        *Regexp
+	usesStart bool // \G ties matches to the search position
+
+	text   []rune
+	pos    int     // first starting position that isn't decided yet
+	r      *runner // holds the attempt suspended at the end of the input
+	closed bool
+	state  MatchState
+	match  *Match
+}
+
+// NewIncrementalMatcher returns a matcher that finds the first match of re in input
+// passed to its Feed methods
+func (re *Regexp) NewIncrementalMatcher() *IncrementalMatcher {
+	return &IncrementalMatcher{
+		re:        re,
+		usesStart: re.code.UsesOp(syntax.Start),
+	}
+}
+
+// FeedString appends s to the input and reports the outcome so far.
+// Once the outcome is decided further input is ignored.
+// error will be set if a timeout occurs
+func (m *IncrementalMatcher) FeedString(s string) (MatchState, error) {
+	if m.state != NeedMoreInput || m.closed {
+		return m.state, nil
+	}
+	return m.FeedRunes(getRunes(s))
+}
+
+// FeedRunes appends r to the input and reports the outcome so far.
+// Once the outcome is decided further input is ignored.
+// error will be set if a timeout occurs
+func (m *IncrementalMatcher) FeedRunes(r []rune) (MatchState, error) {
+	if m.state != NeedMoreInput || m.closed {
+		return m.state, nil
+	}
+	m.text = append(m.text, r...)
+	return m.search()
+}
+
+// Close marks the end of the input, which always decides the outcome
+func (m *IncrementalMatcher) Close() (MatchState, error) {
+	if m.state != NeedMoreInput || m.closed {
+		return m.state, nil
+	}
+	m.closed = true
+	return m.search()
+}
+
+// Match returns the match once the state is Matched, nil otherwise.
+// Its positions are relative to the start of all the input fed so far.
+func (m *IncrementalMatcher) Match() *Match {
+	return m.match
+}
+
+// Reset discards the input so the matcher can be reused
+func (m *IncrementalMatcher) Reset() {
+	m.release()
+	m.text = nil
+	m.pos = 0
+	m.closed = false
+	m.state = NeedMoreInput
+	m.match = nil
+}
+
+func (m *IncrementalMatcher) search() (MatchState, error) {
+	if m.re.RightToLeft() && !m.closed {
+		// the search starts from the end of the input
+		return NeedMoreInput, nil
+	}
+
+	var match *Match
+	var err error
+	if r := m.r; r != nil {
+		// after Close the attempt has to finish rather than wait for more
+		r.runsuspend = !m.closed
+		match, err = r.resume(m.text, m.re.MatchTimeout)
+	} else {
+		m.r = m.re.getRunner()
+		m.r.runtrackhitend = true
+		m.r.runsuspend = !m.closed
+
+		start := m.pos
+		if m.re.RightToLeft() {
+			start = len(m.text)
+		}
+		match, err = m.r.scan(m.text, start, -1, false, m.re.MatchTimeout)
+	}
+	if err != nil {
+		m.release()
+		return m.state, err
+	}
+
+	r := m.r
+	if r.runsuspended {
+		// keep the attempt for the next Feed
+		return NeedMoreInput, nil
+	}
+	hitend, hitstart := r.runhitend, r.runhitstart
+	m.release()
+
+	if m.closed || !hitend {
+		// more input can't change the result
+		m.match = match
+		if match != nil {
+			m.state = Matched
+		} else {
+			m.state = NoMatch
+		}
+		return m.state, nil
+	}
+
+	if hitstart > m.pos && !m.usesStart {
+		// attempts before hitstart failed without needing more input
+		m.pos = hitstart
+	}
+	return NeedMoreInput, nil
+}
+
+// release returns the runner of a suspended attempt to the pool
+func (m *IncrementalMatcher) release() {
+	if m.r != nil {
+		m.re.putRunner(m.r)
+		m.r = nil
+	}
+}
@@ -0,0 +1,127 @@
+package regexp2
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIncrementalMatcher(t *testing.T) {
+	tests := []struct {
+		pattern   string
+		opt       RegexOptions
+		fragments []string
+		states    []MatchState // after each fragment, then after Close
+		match     string
+	}{
+		{`abc`, 0, []string{"xxa", "b", "cyy"}, []MatchState{NeedMoreInput, NeedMoreInput, Matched, Matched}, "abc"},
+		{`abc`, 0, []string{"xxx"}, []MatchState{NeedMoreInput, NoMatch}, ""},
+		{`^abc`, 0, []string{"x"}, []MatchState{NoMatch, NoMatch}, ""},
+		{`^ab`, 0, []string{"a", "bc"}, []MatchState{NeedMoreInput, Matched, Matched}, "ab"},
+		{`a\d+`, 0, []string{"a1", "23", "x"}, []MatchState{NeedMoreInput, NeedMoreInput, Matched, Matched}, "a123"},
+		{`a\d+`, 0, []string{"a1", "23"}, []MatchState{NeedMoreInput, NeedMoreInput, Matched}, "a123"},
+		{`a\d+?`, 0, []string{"a1", "23"}, []MatchState{Matched, Matched, Matched}, "a1"},
+		{`a\d*?x`, 0, []string{"a1", "2x"}, []MatchState{NeedMoreInput, Matched, Matched}, "a12x"},
+		{`(?:ab|a)c`, 0, []string{"a", "b", "c"}, []MatchState{NeedMoreInput, NeedMoreInput, Matched, Matched}, "abc"},
+		{`(\w+)\d`, 0, []string{"ab", "c1", "!"}, []MatchState{NeedMoreInput, NeedMoreInput, Matched, Matched}, "abc1"},
+		{`\bfoo\b`, 0, []string{"foo", "d foo", " "}, []MatchState{NeedMoreInput, NeedMoreInput, Matched, Matched}, "foo"},
+		{`a{3}b`, 0, []string{"aa", "ab"}, []MatchState{NeedMoreInput, Matched, Matched}, "aaab"},
+		{`end$`, 0, []string{"the end", " is near"}, []MatchState{NeedMoreInput, NeedMoreInput, NoMatch}, ""},
+		{`end$`, 0, []string{"the end"}, []MatchState{NeedMoreInput, Matched}, "end"},
+		{`cat|category`, 0, []string{"categ", "ory"}, []MatchState{Matched, Matched, Matched}, "cat"},
+		{`category|cat`, 0, []string{"categ", "orx"}, []MatchState{NeedMoreInput, Matched, Matched}, "cat"},
+		{`b`, RightToLeft, []string{"abcb", "x"}, []MatchState{NeedMoreInput, NeedMoreInput, Matched}, "b"},
+	}
+
+	for _, test := range tests {
+		m := MustCompile(test.pattern, test.opt).NewIncrementalMatcher()
+		for i, f := range test.fragments {
+			state, err := m.FeedString(f)
+			if err != nil {
+				t.Fatalf("Unexpected err: %v", err)
+			}
+			if want, got := test.states[i], state; want != got {
+				t.Fatalf("pattern %q fragment %v: Wanted '%v'\nGot '%v'", test.pattern, i, want, got)
+			}
+		}
+		state, err := m.Close()
+		if err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+		if want, got := test.states[len(test.states)-1], state; want != got {
+			t.Fatalf("pattern %q close: Wanted '%v'\nGot '%v'", test.pattern, want, got)
+		}
+
+		got := ""
+		if m.Match() != nil {
+			got = m.Match().String()
+		}
+		if want := test.match; want != got {
+			t.Fatalf("pattern %q: Wanted '%v'\nGot '%v'", test.pattern, want, got)
+		}
+	}
+}
+
+func TestIncrementalMatcher_SkipsDecidedPositions(t *testing.T) {
+	m := MustCompile(`ab`, 0).NewIncrementalMatcher()
+	if _, err := m.FeedString(strings.Repeat("x", 1000) + "a"); err != nil {
+		t.Fatalf("Unexpected err: %v", err)
+	}
+	if want, got := 1000, m.pos; want != got {
+		t.Fatalf("Wanted '%v'\nGot '%v'", want, got)
+	}
+
+	state, err := m.FeedString("b")
+	if err != nil {
+		t.Fatalf("Unexpected err: %v", err)
+	}
+	if state != Matched || m.Match().Index != 1000 {
+		t.Fatalf("Expected match at 1000, got %v %v", state, m.Match())
+	}
+
+	m.Reset()
+	if state, _ := m.FeedString("ab"); state != Matched || m.Match().Index != 0 {
+		t.Fatalf("Expected match at 0 after Reset, got %v %v", state, m.Match())
+	}
+}
+
+func TestIncrementalMatcher_ResumesAttempt(t *testing.T) {
+	m := MustCompile(`a[^;]*;`, 0).NewIncrementalMatcher()
+	for i := 0; i < 1000; i++ {
+		state, err := m.FeedString("a")
+		if err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+		if state != NeedMoreInput {
+			t.Fatalf("Wanted NeedMoreInput, got %v", state)
+		}
+	}
+	// the attempt from the first char is still running rather than restarted
+	if m.r == nil || m.r.runattempt != 0 {
+		t.Fatal("Expected the attempt at 0 to be suspended")
+	}
+
+	state, err := m.FeedString(";")
+	if err != nil {
+		t.Fatalf("Unexpected err: %v", err)
+	}
+	if state != Matched || m.Match().Length != 1001 {
+		t.Fatalf("Expected a match of 1001 chars, got %v %v", state, m.Match())
+	}
+	if m.r != nil {
+		t.Fatal("Expected the runner to be released")
+	}
+}
+
+func BenchmarkIncrementalMatcher_RuneAtATime(b *testing.B) {
+	re := MustCompile(`a[^;]*;`, 0)
+	for i := 0; i < b.N; i++ {
+		m := re.NewIncrementalMatcher()
+		m.FeedString("a")
+		for j := 0; j < 10000; j++ {
+			m.FeedString("b")
+		}
+		if state, _ := m.FeedString(";"); state != Matched {
+			b.Fatalf("Expected a match, got %v", state)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package regexp2
+
+import "errors"
+
+// Input is text that a Regexp can search without it being flattened into a single
+// rune slice first, such as a rope or a gap buffer.  Positions are rune indexes.
+// The text must not change while a search runs or while its matches are in use.
+//
+// The string and rune slice APIs behave exactly as if they searched an Input over
+// the runes of their argument.
+type Input interface {
+	// Len returns the number of runes in the text
+	Len() int
+	// RuneAt returns the rune at index i, where 0 <= i < Len()
+	RuneAt(i int) rune
+}
+
+// ChunkedInput is an Input stored as contiguous runs of runes.  The runner reads
+// the runs directly rather than calling RuneAt for every rune.
+type ChunkedInput interface {
+	Input
+	// Chunk returns the run of runes containing index i, where 0 <= i < Len(),
+	// along with the index of its first rune
+	Chunk(i int) (chunk []rune, start int)
+}
+
+// runeInput is the Input over a rune slice
+type runeInput []rune
+
+func (r runeInput) Len() int {
+	return len(r)
+}
+
+func (r runeInput) RuneAt(i int) rune {
+	return r[i]
+}
+
+func (r runeInput) Chunk(i int) ([]rune, int) {
+	return r, 0
+}
+
+// contiguousRunes returns the runes of in if it's stored as a single slice
+func contiguousRunes(in Input) ([]rune, bool) {
+	switch in := in.(type) {
+	case runeInput:
+		return in, true
+	case ChunkedInput:
+		l := in.Len()
+		if l == 0 {
+			return []rune{}, true
+		}
+		if chunk, start := in.Chunk(0); start == 0 && len(chunk) >= l {
+			return chunk[:l], true
+		}
+	}
+	return nil, false
+}
+
+// inputRunes returns the runes of in from start up to end.  The result shares
+// memory with contiguous text, matching the behavior of the rune slice APIs.
+func inputRunes(text []rune, in Input, start, end int) []rune {
+	if in == nil {
+		return text[start:end]
+	}
+	if c, ok := in.(ChunkedInput); ok && start < end {
+		if chunk, cstart := c.Chunk(start); end-cstart <= len(chunk) {
+			return chunk[start-cstart : end-cstart]
+		}
+	}
+
+	ret := make([]rune, end-start)
+	for i := range ret {
+		ret[i] = in.RuneAt(start + i)
+	}
+	return ret
+}
+
+// FindInputMatch searches the input for a regex match.
+// error will be set if a timeout occurs
+func (re *Regexp) FindInputMatch(in Input) (*Match, error) {
+	return re.runInput(false, -1, -1, in)
+}
+
+// FindInputMatchStartingAt searches the input for a regex match starting at the startAt index.
+// error will be set if a timeout occurs
+func (re *Regexp) FindInputMatchStartingAt(in Input, startAt int) (*Match, error) {
+	if startAt > in.Len() {
+		return nil, errors.New("startAt must be less than the length of the input")
+	}
+	return re.runInput(false, startAt, -1, in)
+}
+
+// MatchInput returns true if the input matches the regex.
+// error will be set if a timeout occurs
+func (re *Regexp) MatchInput(in Input) (bool, error) {
+	m, err := re.runInput(true, -1, -1, in)
+	if err != nil {
+		return false, err
+	}
+	return m != nil, nil
+}
+
+// runInput is like runRange for text that may not be a rune slice
+func (re *Regexp) runInput(quick bool, textstart, textstop int, in Input) (*Match, error) {
+	if rt, ok := contiguousRunes(in); ok {
+		return re.runRange(quick, textstart, textstop, rt)
+	}
+
+	runner := re.getRunner()
+	defer re.putRunner(runner)
+
+	if textstart < 0 {
+		if re.RightToLeft() {
+			textstart = in.Len()
+		} else {
+			textstart = 0
+		}
+	}
+
+	runner.setInput(nil, in)
+	return runner.scanText(textstart, textstop, quick, re.MatchTimeout)
+}
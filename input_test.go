@@ -0,0 +1,144 @@
+package regexp2
+
+import (
+	"strings"
+	"testing"
+)
+
+// ropeInput stores its text in fixed size chunks
+type ropeInput struct {
+	chunks [][]rune
+	size   int
+	length int
+}
+
+func newRopeInput(s string, size int) *ropeInput {
+	r := []rune(s)
+	in := &ropeInput{size: size, length: len(r)}
+	for len(r) > size {
+		in.chunks = append(in.chunks, r[:size:size])
+		r = r[size:]
+	}
+	in.chunks = append(in.chunks, r)
+	return in
+}
+
+func (in *ropeInput) Len() int {
+	return in.length
+}
+
+func (in *ropeInput) RuneAt(i int) rune {
+	return in.chunks[i/in.size][i%in.size]
+}
+
+func (in *ropeInput) Chunk(i int) ([]rune, int) {
+	return in.chunks[i/in.size], i / in.size * in.size
+}
+
+// randomAccessInput only implements Input
+type randomAccessInput struct {
+	r []rune
+}
+
+func (in randomAccessInput) Len() int {
+	return len(in.r)
+}
+
+func (in randomAccessInput) RuneAt(i int) rune {
+	return in.r[i]
+}
+
+func TestInput_SameAsString(t *testing.T) {
+	input := strings.Repeat("The quick brown fox jumps over the lazy dog.\n", 20) + "Fin"
+
+	tests := []struct {
+		pattern string
+		opt     RegexOptions
+	}{
+		{`fox`, 0},
+		{`FOX`, IgnoreCase},
+		{`fox`, RightToLeft},
+		{`^The`, Multiline},
+		{`\AThe quick`, 0},
+		{`Fin\z`, 0},
+		{`\w+$`, Multiline},
+		{`(\w)\1`, 0},
+		{`(?<=o)\w+`, 0},
+		{`\b(?<word>\w{4})\b`, 0},
+		{`dog\.\s*(?:Fin)?`, RightToLeft},
+		{``, 0},
+	}
+
+	for _, test := range tests {
+		re := MustCompile(test.pattern, test.opt)
+		want, err := re.findAllSequential([]rune(input))
+		if err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+
+		for _, in := range []Input{newRopeInput(input, 7), randomAccessInput{[]rune(input)}} {
+			var got []*Match
+			m, err := re.FindInputMatch(in)
+			for m != nil {
+				got = append(got, m)
+				m, err = re.FindNextMatch(m)
+			}
+			if err != nil {
+				t.Fatalf("Unexpected err: %v", err)
+			}
+
+			if len(want) != len(got) {
+				t.Fatalf("pattern %q %T: Wanted %v matches\nGot %v", test.pattern, in, len(want), len(got))
+			}
+			for i := range want {
+				wg, gg := want[i].Groups(), got[i].Groups()
+				for j := range wg {
+					if wg[j].Index != gg[j].Index || wg[j].String() != gg[j].String() {
+						t.Fatalf("pattern %q %T match %v group %v: Wanted '%v' at %v\nGot '%v' at %v",
+							test.pattern, in, i, j, wg[j].String(), wg[j].Index, gg[j].String(), gg[j].Index)
+					}
+				}
+			}
+		}
+
+		isMatch, err := re.MatchInput(newRopeInput(input, 5))
+		if err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+		if want, got := len(want) > 0, isMatch; want != got {
+			t.Fatalf("pattern %q: Wanted '%v'\nGot '%v'", test.pattern, want, got)
+		}
+	}
+}
+
+func TestInput_StartingAt(t *testing.T) {
+	re := MustCompile(`\d+`, 0)
+	in := newRopeInput("a1 b22 c333", 2)
+
+	m, err := re.FindInputMatchStartingAt(in, 3)
+	if err != nil {
+		t.Fatalf("Unexpected err: %v", err)
+	}
+	if want, got := "22", m.String(); want != got {
+		t.Fatalf("Wanted '%v'\nGot '%v'", want, got)
+	}
+
+	if _, err := re.FindInputMatchStartingAt(in, 20); err == nil {
+		t.Fatalf("Expected an error for startAt past the end")
+	}
+}
+
+func TestInput_Contiguous(t *testing.T) {
+	// a single chunk is searched like a rune slice and shares its memory
+	r := []rune("abc def")
+	m, err := MustCompile(`def`, 0).FindInputMatch(newRopeInput(string(r), 100))
+	if err != nil {
+		t.Fatalf("Unexpected err: %v", err)
+	}
+	if m.input != nil {
+		t.Fatalf("Expected a contiguous input to be searched as a rune slice")
+	}
+	if want, got := "def", m.String(); want != got {
+		t.Fatalf("Wanted '%v'\nGot '%v'", want, got)
+	}
+}
@@ -0,0 +1,56 @@
+//go:build go1.23
+
+package regexp2
+
+import "iter"
+
+// AllMatches returns an iterator over every successive match in the input string, the
+// same matches a FindStringMatch/FindNextMatch loop finds.  An error, such as a timeout,
+// is yielded with a nil Match and ends the iteration.
+//
+// The input is converted to runes when the iteration starts, and one runner is used for
+// the whole iteration.  It goes back to the pool as soon as the loop ends or breaks.
+func (re *Regexp) AllMatches(s string) iter.Seq2[*Match, error] {
+	return func(yield func(*Match, error) bool) {
+		re.allMatches(getRunes(s), yield)
+	}
+}
+
+// AllRunesMatches is like AllMatches but iterates over the matches in a rune slice
+func (re *Regexp) AllRunesMatches(r []rune) iter.Seq2[*Match, error] {
+	return func(yield func(*Match, error) bool) {
+		re.allMatches(r, yield)
+	}
+}
+
+// AllBytesMatches is like AllMatches but iterates over the matches in UTF-8 encoded bytes.
+// Match positions are in runes, as they are for strings.
+func (re *Regexp) AllBytesMatches(b []byte) iter.Seq2[*Match, error] {
+	return func(yield func(*Match, error) bool) {
+		re.allMatches([]rune(string(b)), yield)
+	}
+}
+
+func (re *Regexp) allMatches(r []rune, yield func(*Match, error) bool) {
+	runner := re.getRunner()
+	defer re.putRunner(runner)
+
+	startAt := 0
+	if re.RightToLeft() {
+		startAt = len(r)
+	}
+
+	for {
+		m, err := runner.scan(r, startAt, -1, false, re.MatchTimeout)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		if m == nil || !yield(m, nil) {
+			return
+		}
+		if startAt = re.nextMatchStart(m); startAt < 0 {
+			return
+		}
+	}
+}
@@ -0,0 +1,87 @@
+//go:build go1.23
+
+package regexp2
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAllMatches(t *testing.T) {
+	input := "one two  three\nfour"
+	for _, p := range []string{`\w+`, `\s*`, `^\w+$`} {
+		for _, opt := range []RegexOptions{0, RightToLeft, Multiline} {
+			re := MustCompile(p, opt)
+			want, err := re.findAllSequential([]rune(input))
+			if err != nil {
+				t.Fatalf("Unexpected err: %v", err)
+			}
+
+			seqs := map[string]func(yield func(*Match, error) bool){
+				"string": re.AllMatches(input),
+				"runes":  re.AllRunesMatches([]rune(input)),
+				"bytes":  re.AllBytesMatches([]byte(input)),
+			}
+			for name, seq := range seqs {
+				var got []*Match
+				for m, err := range seq {
+					if err != nil {
+						t.Fatalf("Unexpected err: %v", err)
+					}
+					got = append(got, m)
+				}
+
+				if len(want) != len(got) {
+					t.Fatalf("pattern %q %v: Wanted %v matches\nGot %v", p, name, len(want), len(got))
+				}
+				for i := range want {
+					if want[i].Index != got[i].Index || want[i].String() != got[i].String() {
+						t.Fatalf("pattern %q %v: Wanted '%v' at %v\nGot '%v' at %v",
+							p, name, want[i].String(), want[i].Index, got[i].String(), got[i].Index)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestAllMatches_Break(t *testing.T) {
+	re := MustCompile(`\d`, 0)
+	var got []string
+	for m, err := range re.AllMatches("1a2b3c4") {
+		if err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+		got = append(got, m.String())
+		if len(got) == 2 {
+			break
+		}
+	}
+	if want, got := "1 2", strings.Join(got, " "); want != got {
+		t.Fatalf("Wanted '%v'\nGot '%v'", want, got)
+	}
+
+	// the runner went back to the pool without holding on to the text
+	r := re.getRunner()
+	defer re.putRunner(r)
+	if r.runtext != nil {
+		t.Fatalf("Expected the pooled runner to have released the text")
+	}
+}
+
+func TestAllMatches_Timeout(t *testing.T) {
+	re := MustCompile(`(a+)+b`, 0)
+	re.MatchTimeout = time.Millisecond * 10
+
+	errs := 0
+	for m, err := range re.AllMatches(strings.Repeat("a", 40)) {
+		if err == nil || m != nil {
+			t.Fatalf("Expected only a timeout, got %v %v", m, err)
+		}
+		errs++
+	}
+	if errs != 1 {
+		t.Fatalf("Wanted 1 error\nGot %v", errs)
+	}
+}
@@ -29,6 +29,12 @@ type Match struct {
 	// whether we've done any balancing with this match.  If we
 	// have done balancing, we'll need to do extra work in Tidy().
 	balancing bool
+
+	// whether the search examined the end of the input
+	hitEnd bool
+
+	// set when the search was restricted to part of the text
+	region *region
 }
 
 // Group is an explicit or implit (group 0) matched group within the pattern
@@ -37,12 +43,17 @@ type Group struct {
 
 	Name     string    // group name
 	Captures []Capture // captures of this group
+	// Success is true if the group participated in the match, even with an empty
+	// capture.  A group that didn't has no Captures and an empty Capture at index 0.
+	Success bool
 }
 
 // Capture is a single capture of text within the larger original string
 type Capture struct {
 	// the original string
 	text []rune
+	// the original text when it isn't a rune slice, text is nil then
+	input Input
 	// the position in the original string where the first character of
 	// captured substring was found.
 	Index int
@@ -52,12 +63,12 @@ type Capture struct {
 
 // String returns the captured text as a String
 func (c *Capture) String() string {
-	return string(c.text[c.Index : c.Index+c.Length])
+	return string(c.Runes())
 }
 
 // Runes returns the captured text as a rune slice
 func (c *Capture) Runes() []rune {
-	return c.text[c.Index : c.Index+c.Length]
+	return inputRunes(c.text, c.input, c.Index, c.Index+c.Length)
 }
 
 func newMatch(regex *Regexp, capcount int, text []rune, startpos int) *Match {
@@ -89,6 +100,58 @@ func (m *Match) reset(text []rune, textstart int) {
 	m.balancing = false
 }
 
+// clone returns a copy of the match whose captures are independent of m's
+func (m *Match) clone() *Match {
+	c := *m
+	c.otherGroups = nil
+	c.matchcount = append([]int(nil), m.matchcount...)
+	c.matches = make([][]int, len(m.matches))
+	for i := range m.matches {
+		c.matches[i] = append([]int(nil), m.matches[i][:m.matchcount[i]*2]...)
+	}
+	return &c
+}
+
+// sameCaptures returns true if both matches captured the same text in every group
+func (m *Match) sameCaptures(o *Match) bool {
+	for i := range m.matchcount {
+		if m.matchcount[i] != o.matchcount[i] {
+			return false
+		}
+		for j := 0; j < m.matchcount[i]*2; j++ {
+			if m.matches[i][j] != o.matches[i][j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// groupsPreferredTo returns true if the first group that differs between the
+// matches participated, started earlier or is longer in m
+func (m *Match) groupsPreferredTo(o *Match) bool {
+	for i := 1; i < len(m.matchcount); i++ {
+		mc, oc := m.matchcount[i], o.matchcount[i]
+		if mc == 0 || oc == 0 {
+			if mc != oc {
+				return mc > 0
+			}
+			continue
+		}
+
+		// compare the last captures
+		mi, ml := m.matches[i][(mc-1)*2], m.matches[i][mc*2-1]
+		oi, ol := o.matches[i][(oc-1)*2], o.matches[i][oc*2-1]
+		if mi != oi {
+			return mi < oi
+		}
+		if ml != ol {
+			return ml > ol
+		}
+	}
+	return false
+}
+
 func (m *Match) tidy(textpos int) {
 
 	interval := m.matches[0]
@@ -98,6 +161,7 @@ func (m *Match) tidy(textpos int) {
 	m.capcount = m.matchcount[0]
 	//copy our root capture to the list
 	m.Group.Captures = []Capture{m.Group.Capture}
+	m.Group.Success = true
 
 	if m.balancing {
 		// The idea here is that we want to compact all of our unbalanced captures.  To do that we
@@ -225,7 +289,17 @@ func (m *Match) GroupCount() int {
 	return len(m.matchcount)
 }
 
-// GroupByName returns a group based on the name of the group, or nil if the group name does not exist
+// HitEnd returns true if the search that found this match examined the end of the input,
+// so more input could have produced a different match.  It can also be true when a
+// repeat or string was cut short by the end of the input without checking whether it
+// matched up to there; MatchPartial is exact.  It isn't tracked reliably for
+// RightToLeft patterns.
+func (m *Match) HitEnd() bool {
+	return m.hitEnd
+}
+
+// GroupByName returns a group based on the name of the group, or nil if the group name does not exist.
+// A group that exists but didn't participate in the match is returned with Success false.
 func (m *Match) GroupByName(name string) *Group {
 	num := m.regex.GroupNumberFromName(name)
 	if num < 0 {
@@ -234,13 +308,16 @@ func (m *Match) GroupByName(name string) *Group {
 	return m.GroupByNumber(num)
 }
 
-// GroupByNumber returns a group based on the number of the group, or nil if the group number does not exist
+// GroupByNumber returns a group based on the number of the group, or nil if the group number does not exist.
+// A group that exists but didn't participate in the match is returned with Success false.
 func (m *Match) GroupByNumber(num int) *Group {
 	// check our sparse map
 	if m.sparseCaps != nil {
-		if newNum, ok := m.sparseCaps[num]; ok {
-			num = newNum
+		newNum, ok := m.sparseCaps[num]
+		if !ok {
+			return nil
 		}
+		num = newNum
 	}
 	if num >= len(m.matchcount) || num < 0 {
 		return nil
@@ -267,14 +344,16 @@ func (m *Match) Groups() []Group {
 func (m *Match) populateOtherGroups() {
 	// Construct all the Group objects first time called
 	if m.otherGroups == nil {
+		// groups are stored by slot, which isn't the group number with sparse caps
+		nums := m.regex.GetGroupNumbers()
 		m.otherGroups = make([]Group, len(m.matchcount)-1)
 		for i := 0; i < len(m.otherGroups); i++ {
-			m.otherGroups[i] = newGroup(m.regex.GroupNameFromNumber(i+1), m.text, m.matches[i+1], m.matchcount[i+1])
+			m.otherGroups[i] = newGroup(m.regex.GroupNameFromNumber(nums[i+1]), m.text, m.input, m.matches[i+1], m.matchcount[i+1])
 		}
 	}
 }
 
-func (m *Match) groupValueAppendToBuf(groupnum int, buf *bytes.Buffer) {
+func (m *Match) groupValueAppendToBuf(groupnum int, rw *runeWriter) {
 	c := m.matchcount[groupnum]
 	if c == 0 {
 		return
@@ -285,23 +364,37 @@ func (m *Match) groupValueAppendToBuf(groupnum int, buf *bytes.Buffer) {
 	index := matches[(c-1)*2]
 	last := index + matches[(c*2)-1]
 
-	for ; index < last; index++ {
-		buf.WriteRune(m.text[index])
+	rw.writeRunes(inputRunes(m.text, m.input, index, last))
+}
+
+// textAppendToBuf writes the searched text from start up to end
+func (m *Match) textAppendToBuf(start, end int, rw *runeWriter) {
+	rw.writeRunes(inputRunes(m.text, m.input, start, end))
+}
+
+// textLen returns the length of the text that was searched
+func (m *Match) textLen() int {
+	if m.input != nil {
+		return m.input.Len()
 	}
+	return len(m.text)
 }
 
-func newGroup(name string, text []rune, caps []int, capcount int) Group {
+func newGroup(name string, text []rune, input Input, caps []int, capcount int) Group {
 	g := Group{}
 	g.text = text
+	g.input = input
 	if capcount > 0 {
 		g.Index = caps[(capcount-1)*2]
 		g.Length = caps[(capcount*2)-1]
 	}
 	g.Name = name
+	g.Success = capcount > 0
 	g.Captures = make([]Capture, capcount)
 	for i := 0; i < capcount; i++ {
 		g.Captures[i] = Capture{
 			text:   text,
+			input:  input,
 			Index:  caps[i*2],
 			Length: caps[i*2+1],
 		}
@@ -0,0 +1,166 @@
+package regexp2
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/dlclark/regexp2/syntax"
+)
+
+// minParallelChunkSize is the smallest number of runes handed to a single
+// goroutine by FindAllParallel.  Smaller inputs are scanned sequentially.
+const minParallelChunkSize = 64 * 1024
+
+// FindAllParallel returns every successive match in the input string, exactly as a
+// FindStringMatch/FindNextMatch loop would, but splits large inputs into chunks that
+// are scanned concurrently on up to workers goroutines.  If workers is less than 1
+// then runtime.GOMAXPROCS(0) is used.
+//
+// Each chunk overlaps the next by at most the pattern's maximum match length; the
+// overlap is reconciled when the results are merged.  Patterns without a bounded
+// match length, RightToLeft patterns, and patterns using \G are always scanned
+// sequentially.  The first error encountered (such as a timeout) is returned.
+func (re *Regexp) FindAllParallel(s string, workers int) ([]*Match, error) {
+	return re.FindAllRunesParallel(getRunes(s), workers)
+}
+
+// FindAllRunesParallel is like FindAllParallel but searches a rune slice
+func (re *Regexp) FindAllRunesParallel(r []rune, workers int) ([]*Match, error) {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	chunkCount := workers
+	if n := len(r) / minParallelChunkSize; n < chunkCount {
+		chunkCount = n
+	}
+	if chunkCount < 2 || !re.canScanParallel() {
+		return re.findAllSequential(r)
+	}
+
+	// scan each chunk on its own runner
+	chunks := make([]parallelChunk, chunkCount)
+	size := (len(r) + chunkCount - 1) / chunkCount
+	var wg sync.WaitGroup
+	for i := range chunks {
+		c := &chunks[i]
+		c.start = i * size
+		c.end = c.start + size
+		if i == len(chunks)-1 {
+			// an empty match may start at the very end of the input
+			c.end = len(r) + 1
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.err = re.scanChunk(r, c)
+		}()
+	}
+	wg.Wait()
+
+	for i := range chunks {
+		if chunks[i].err != nil {
+			return nil, chunks[i].err
+		}
+	}
+
+	return re.mergeChunks(r, chunks)
+}
+
+// parallelChunk holds the matches that start in [start, end) when searching
+// the chunk on its own.  searchStarts[i] is the position the search for
+// matches[i] began at; the final entry is where the last, failed search began.
+type parallelChunk struct {
+	start, end   int
+	matches      []*Match
+	searchStarts []int
+	err          error
+}
+
+// canScanParallel tells if the results of searching chunks independently can
+// be stitched back together into the sequential result
+func (re *Regexp) canScanParallel() bool {
+	return !re.RightToLeft() && re.code.MaxLength >= 0 && !re.code.UsesOp(syntax.Start)
+}
+
+func (re *Regexp) findAllSequential(r []rune) ([]*Match, error) {
+	var matches []*Match
+	m, err := re.FindRunesMatch(r)
+	for m != nil {
+		matches = append(matches, m)
+		m, err = re.FindNextMatch(m)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// scanChunk finds the matches that start in the chunk.  The runner sees the
+// whole input so anchors and lookarounds behave exactly as in a sequential scan.
+func (re *Regexp) scanChunk(r []rune, c *parallelChunk) error {
+	pos := c.start
+	for {
+		c.searchStarts = append(c.searchStarts, pos)
+		m, err := re.runRange(false, pos, c.end-1, r)
+		if err != nil || m == nil {
+			return err
+		}
+		c.matches = append(c.matches, m)
+
+		if pos = re.nextMatchStart(m); pos < 0 || pos >= c.end {
+			c.searchStarts = append(c.searchStarts, pos)
+			return nil
+		}
+	}
+}
+
+// mergeChunks walks the chunks in order keeping track of where a sequential
+// scan would resume.  Once a chunk's own search reached that position its
+// matches are identical to the sequential ones; until then the gap left by a
+// match spilling over from the previous chunk is rescanned.
+func (re *Regexp) mergeChunks(r []rune, chunks []parallelChunk) ([]*Match, error) {
+	var matches []*Match
+	pos := 0
+
+	for i := range chunks {
+		c := &chunks[i]
+		if pos < c.start {
+			// nothing starts between the previous chunk's last match and here
+			pos = c.start
+		}
+
+		j := 0
+		for pos >= 0 && pos < c.end {
+			for j < len(c.matches) && c.matches[j].Index < pos {
+				j++
+			}
+			if s := c.searchStarts[j]; s >= 0 && s <= pos {
+				// in sync with the chunk's own search
+				matches = append(matches, c.matches[j:]...)
+				if len(c.matches) > j {
+					pos = re.nextMatchStart(c.matches[len(c.matches)-1])
+				}
+				break
+			}
+
+			m, err := re.runRange(false, pos, c.end-1, r)
+			if err != nil {
+				return nil, err
+			}
+			if m == nil {
+				break
+			}
+			matches = append(matches, m)
+			pos = re.nextMatchStart(m)
+		}
+
+		if pos < 0 {
+			// an empty match at the end of the input finishes the scan
+			break
+		}
+	}
+
+	return matches, nil
+}
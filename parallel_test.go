@@ -0,0 +1,109 @@
+package regexp2
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func parallelTestInput(n int) string {
+	words := []string{"ab", "a", "abc", "1234", "42", " ", "\n", "xyz", "xy", "", "aaaa"}
+	rnd := rand.New(rand.NewSource(1))
+	buf := &strings.Builder{}
+	for buf.Len() < n {
+		buf.WriteString(words[rnd.Intn(len(words))])
+	}
+	return buf.String()
+}
+
+func TestFindAllParallel_SameAsSequential(t *testing.T) {
+	input := parallelTestInput(4 * minParallelChunkSize)
+
+	tests := []struct {
+		pattern string
+		opt     RegexOptions
+	}{
+		{`a|ab`, 0},
+		{`\b\d{2,4}\b`, 0},
+		{`(?<=x)y`, 0},
+		{``, 0},
+		{`x?`, 0},
+		{`^[a-c]{1,3}$`, Multiline},
+		{`a{1,100000}`, 0},
+		{`(?<word>[abc]{2})(?=\s)`, 0},
+		{`\w+`, 0},
+		{`\d+\G`, 0},
+		{`\s{1,3}`, RightToLeft},
+	}
+
+	for _, test := range tests {
+		re := MustCompile(test.pattern, test.opt)
+		want, err := re.findAllSequential([]rune(input))
+		if err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+		got, err := re.FindAllParallel(input, 4)
+		if err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+
+		if len(want) != len(got) {
+			t.Fatalf("%v: wanted %v matches, got %v", test.pattern, len(want), len(got))
+		}
+		for i := range want {
+			if want[i].Index != got[i].Index || want[i].Length != got[i].Length {
+				t.Fatalf("%v: match %v wanted (%v, %v), got (%v, %v)", test.pattern, i,
+					want[i].Index, want[i].Length, got[i].Index, got[i].Length)
+			}
+		}
+	}
+}
+
+func TestFindAllParallel_SpanningMatches(t *testing.T) {
+	// every match spans a chunk boundary so each merge needs a rescan
+	input := strings.Repeat("a", 4*minParallelChunkSize+7)
+	re := MustCompile(`a{1,50000}`, 0)
+
+	got, err := re.FindAllParallel(input, 4)
+	if err != nil {
+		t.Fatalf("Unexpected err: %v", err)
+	}
+
+	pos := 0
+	for _, m := range got {
+		if m.Index != pos {
+			t.Fatalf("expected match at %v, got %v", pos, m.Index)
+		}
+		pos += m.Length
+	}
+	if pos != len(input) {
+		t.Fatalf("expected matches to cover the input, ended at %v", pos)
+	}
+}
+
+func TestFindAllParallel_SmallInput(t *testing.T) {
+	re := MustCompile(`\d+`, 0)
+	got, err := re.FindAllParallel("a1b22c333", 0)
+	if err != nil {
+		t.Fatalf("Unexpected err: %v", err)
+	}
+	var strs []string
+	for _, m := range got {
+		strs = append(strs, m.String())
+	}
+	if want, got := "1,22,333", strings.Join(strs, ","); want != got {
+		t.Fatalf("Wanted '%v'\nGot '%v'", want, got)
+	}
+}
+
+func BenchmarkFindAllParallel(b *testing.B) {
+	b.StopTimer()
+	input := []rune(parallelTestInput(1 << 20))
+	re := MustCompile(`\b\d{2,4}\b`, 0)
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := re.FindAllRunesParallel(input, 0); err != nil {
+			b.Fatalf("Unexpected err: %v", err)
+		}
+	}
+}
@@ -10,6 +10,7 @@ package regexp2
 
 import (
 	"errors"
+	"io"
 	"math"
 	"strconv"
 	"sync"
@@ -31,16 +32,23 @@ type Regexp struct {
 	pattern string       // as passed to Compile
 	options RegexOptions // options
 
-	caps     map[int]int    // capnum->index
-	capnames map[string]int //capture group name -> index
-	capslist []string       //sorted list of capture group names
-	capsize  int            // size of the capture array
+	caps      map[int]int    // capnum->index
+	capnames  map[string]int //capture group name -> index
+	capslist  []string       //sorted list of capture group names
+	capsize   int            // size of the capture array
+	capsorder []int          // group numbers in pattern order
 
 	code *syntax.Code // compiled program
 
+	longest bool // whether the longest match at a position is preferred, see Longest
+
 	// cache of machines for running regexp
 	muRun  sync.Mutex
 	runner []*runner
+
+	// recently used replacement patterns, see replacerData
+	muReplace sync.Mutex
+	replacers map[replacerKey]*syntax.ReplacerData
 }
 
 // Compile parses a regular expression and returns, if successful,
@@ -66,6 +74,7 @@ func Compile(expr string, opt RegexOptions) (*Regexp, error) {
 		capnames:     tree.Capnames,
 		capslist:     tree.Caplist,
 		capsize:      code.Capsize,
+		capsorder:    tree.Caporder,
 		code:         code,
 		MatchTimeout: DefaultMatchTimeout,
 	}, nil
@@ -97,6 +106,67 @@ func (re *Regexp) String() string {
 	return re.pattern
 }
 
+// ReleaseMemory drops all idle runners cached by the Regexp so that their
+// backtracking stacks can be garbage collected, along with the parsed
+// replacement patterns cached by Replace.  The Regexp remains usable;
+// runners are allocated again as needed.
+func (re *Regexp) ReleaseMemory() {
+	re.muRun.Lock()
+	re.runner = nil
+	re.muRun.Unlock()
+
+	re.muReplace.Lock()
+	re.replacers = nil
+	re.muReplace.Unlock()
+}
+
+// ReplaceOptions select the syntax of the replacement patterns given to ReplaceWithOptions
+// and CompileReplacement: at most one of the dialects, optionally combined with
+// ReplaceCaseConversion and ReplaceConditionals.  Without a dialect the .NET syntax is used,
+// or the JavaScript syntax if the Regexp has the ECMAScript option.
+type ReplaceOptions int32
+
+const (
+	// ReplaceCaseConversion recognizes \U and \L, which change the case of the literal
+	// text and substitutions that follow up to \E, and \u and \l, which change the case of
+	// the next character only.  \\ is a backslash.  Case is mapped rune by rune with the
+	// Unicode tables; \u maps to titlecase.
+	ReplaceCaseConversion ReplaceOptions = 0x0001
+	// ReplaceConditionals recognizes conditional substitutions ${n:+yes:no} and
+	// (?nyes:no), with ${name:+yes:no} and (?{name}yes:no) for named groups, in any
+	// dialect.  They're replaced by yes if group n participated in the match, even with
+	// an empty capture, and by no otherwise.  In (?nyes:no) the group number takes all the
+	// digits that follow, as in Boost, so a yes branch that starts with a digit needs the
+	// ${n:+yes:no} form; anything after the digits, spaces included, is part of yes.  The
+	// :no branch is optional and branches can hold substitutions and other conditionals;
+	// \: \} and \) escape the characters that end a branch.  Any other ( is literal, and
+	// references to undefined groups are errors.
+	ReplaceConditionals ReplaceOptions = 0x0002
+
+	// ReplaceDotNet is the syntax of .NET's Regex.Replace: $n ${n} ${name} $& $` $' $+ $_
+	// and $$.  $n takes all the digits that follow.  References to undefined groups are
+	// left as literal text.
+	ReplaceDotNet ReplaceOptions = 0x0010
+	// ReplaceJavaScript is the syntax of String.prototype.replace: $n $nn $<name> $& $`
+	// $' and $$.  $nn refers to group nn if it's defined, otherwise to group n followed
+	// by a digit.  References to undefined group numbers are left as literal text, as is
+	// $<name> when the pattern has no named groups; otherwise $<name> with an undefined
+	// name is empty.
+	ReplaceJavaScript ReplaceOptions = 0x0020
+	// ReplacePerl is the syntax of Perl's s///: $n ${n} \n $& $` $' $+ and $+{name},
+	// along with octal escapes, the escapes \t \n \r \f \a \e and the case conversions of
+	// ReplaceCaseConversion.  $n takes all the digits that follow while \n is a single
+	// digit not followed by another; \10 is an octal escape.  $+ is the highest numbered
+	// group that participated in the match.  References to undefined groups are empty.
+	// Any other escaped character stands for itself, so \$ is a dollar sign.
+	ReplacePerl ReplaceOptions = 0x0040
+	// ReplacePython is the syntax of Python's re.sub: \g<name> \g<n> \n \nn, octal
+	// escapes (\0, \0oo and \ooo) and the escapes \a \b \f \n \r \t \v and \\.  As in
+	// Python, references to undefined groups and escaped ASCII letters with no meaning
+	// are errors, while other escaped characters keep their backslash.
+	ReplacePython ReplaceOptions = 0x0080
+)
+
 func quote(s string) string {
 	if strconv.CanBackquote(s) {
 		return "`" + s + "`"
@@ -119,10 +189,15 @@ const (
 	IgnorePatternWhitespace              = 0x0020 // "x"
 	RightToLeft                          = 0x0040 // "r"
 	Debug                                = 0x0080 // "d"
-	ECMAScript                           = 0x0100 // "e"
+	ECMAScript                           = 0x0100 // "e"; replacements default to ReplaceJavaScript
 	RE2                                  = 0x0200 // RE2 (regexp package) compatibility mode
 )
 
+// Options returns the options the Regexp was compiled with
+func (re *Regexp) Options() RegexOptions {
+	return re.options
+}
+
 func (re *Regexp) RightToLeft() bool {
 	return re.options&RightToLeft != 0
 }
@@ -131,16 +206,43 @@ func (re *Regexp) Debug() bool {
 	return re.options&Debug != 0
 }
 
+// LiteralPrefix returns a literal string that must begin any match of the
+// regular expression re.  It returns the boolean true if the literal string
+// comprises the entire regular expression.  Case-insensitive and RightToLeft
+// patterns have no literal prefix.
+func (re *Regexp) LiteralPrefix() (prefix string, complete bool) {
+	p, complete := re.code.LiteralPrefix()
+	return string(p), complete
+}
+
+// Longest makes future searches prefer leftmost-longest matches, like POSIX and the
+// Longest method of the regexp package.  At the leftmost position with a match the runner
+// keeps backtracking after each success and returns the longest overall match.  Between
+// matches of the same length the groups decide in order: a group that participated beats
+// one that didn't, then the one starting earlier, then the longer one.
+// Exploring every way to match can take much longer than stopping at the first, so
+// MatchTimeout still applies.
+// This method modifies the Regexp and may not be called concurrently with any other methods.
+func (re *Regexp) Longest() {
+	re.longest = true
+}
+
 // Replace searches the input string and replaces each match found with the replacement text.
 // Count will limit the number of matches attempted and startAt will allow
 // us to skip past possible matches at the start of the input (left or right depending on RightToLeft option).
-// Set startAt and count to -1 to go through the whole string
+// Set startAt and count to -1 to go through the whole string.
+// The replacement uses the .NET syntax, or the JavaScript syntax if re has the ECMAScript
+// option; see ReplaceOptions.
 func (re *Regexp) Replace(input, replacement string, startAt, count int) (string, error) {
-	data, err := syntax.NewReplacerData(replacement, re.caps, re.capsize, re.capnames, syntax.RegexOptions(re.options))
+	return re.ReplaceWithOptions(input, replacement, startAt, count, 0)
+}
+
+// ReplaceWithOptions is like Replace but the syntax of the replacement text is selected by opts
+func (re *Regexp) ReplaceWithOptions(input, replacement string, startAt, count int, opts ReplaceOptions) (string, error) {
+	data, err := re.replacerData(replacement, opts)
 	if err != nil {
 		return "", err
 	}
-	//TODO: cache ReplacerData
 
 	return replace(re, data, nil, input, startAt, count)
 }
@@ -150,9 +252,34 @@ func (re *Regexp) Replace(input, replacement string, startAt, count int) (string
 // us to skip past possible matches at the start of the input (left or right depending on RightToLeft option).
 // Set startAt and count to -1 to go through the whole string.
 func (re *Regexp) ReplaceFunc(input string, evaluator MatchEvaluator, startAt, count int) (string, error) {
+	return replace(re, nil, func(m *Match) (string, error) {
+		return evaluator(*m), nil
+	}, input, startAt, count)
+}
+
+// ReplaceFuncErr is like ReplaceFunc but the evaluator gets a pointer to each match and
+// can stop the replacement by returning an error, which ReplaceFuncErr then returns.
+func (re *Regexp) ReplaceFuncErr(input string, evaluator MatchEvaluatorErr, startAt, count int) (string, error) {
 	return replace(re, nil, evaluator, input, startAt, count)
 }
 
+// ReplaceTo is like Replace but writes the result to w as the matches are found instead
+// of building it in memory.  RightToLeft patterns find all their matches before the
+// output is written.  If writing fails the replacement stops and the error is returned.
+func (re *Regexp) ReplaceTo(w io.Writer, input, replacement string, startAt, count int) error {
+	data, err := re.replacerData(replacement, 0)
+	if err != nil {
+		return err
+	}
+
+	return replaceTo(w, re, data, nil, input, startAt, count)
+}
+
+// ReplaceFuncTo is like ReplaceFuncErr but writes the result to w, like ReplaceTo
+func (re *Regexp) ReplaceFuncTo(w io.Writer, input string, evaluator MatchEvaluatorErr, startAt, count int) error {
+	return replaceTo(w, re, nil, evaluator, input, startAt, count)
+}
+
 // FindStringMatch searches the input string for a Regexp match
 func (re *Regexp) FindStringMatch(s string) (*Match, error) {
 	// convert string to runes
@@ -190,21 +317,122 @@ func (re *Regexp) FindNextMatch(m *Match) (*Match, error) {
 		return nil, nil
 	}
 
-	// If previous match was empty, advance by one before matching to prevent
-	// infinite loop
-	startAt := m.textpos
-	if m.Length == 0 {
-		if m.textpos == len(m.text) {
+	startAt := re.nextMatchStart(m)
+	if startAt < 0 {
+		return nil, nil
+	}
+	return re.findFrom(m, startAt)
+}
+
+// FindNextOverlappingMatch returns the next match in the same input string as the match
+// parameter, including matches that overlap it.  The search resumes one position past where
+// the previous match started (m.Index+1, or m.Index+m.Length-1 for RightToLeft patterns), so
+// a FindStringMatch/FindNextOverlappingMatch loop finds the first match starting at each
+// position.
+// Will return nil if there is no next match or if given a nil match.
+func (re *Regexp) FindNextOverlappingMatch(m *Match) (*Match, error) {
+	if m == nil {
+		return nil, nil
+	}
+
+	beg, end := 0, m.textLen()
+	if m.region != nil {
+		beg, end = m.region.beg, m.region.end
+	}
+
+	var startAt int
+	if re.RightToLeft() {
+		if startAt = m.Index + m.Length - 1; startAt < beg {
 			return nil, nil
 		}
+	} else if startAt = m.Index + 1; startAt > end {
+		return nil, nil
+	}
+	return re.findFrom(m, startAt)
+}
+
+// findFrom searches the text m was found in, starting at startAt
+func (re *Regexp) findFrom(m *Match, startAt int) (*Match, error) {
+	if m.region != nil {
+		return re.runRegion(false, startAt, m.text, m.input, m.region)
+	}
+	if m.input != nil {
+		return re.runInput(false, startAt, -1, m.input)
+	}
+	return re.run(false, startAt, m.text)
+}
+
+// nextMatchStart returns the position FindNextMatch resumes searching from
+// after m, or -1 if there can't be another match.
+func (re *Regexp) nextMatchStart(m *Match) int {
+	beg, end := 0, m.textLen()
+	if m.region != nil {
+		beg, end = m.region.beg, m.region.end
+	}
+	return re.nextStart(m.textpos, m.Length, beg, end)
+}
 
+// nextStart returns where the search after a match of length ending at textpos
+// resumes from, or -1 if there can't be another match in [beg, end].
+func (re *Regexp) nextStart(textpos, length, beg, end int) int {
+	// If previous match was empty, advance by one before matching to prevent
+	// infinite loop
+	startAt := textpos
+	if length == 0 {
 		if re.RightToLeft() {
+			if textpos == beg {
+				return -1
+			}
 			startAt--
 		} else {
+			if textpos == end {
+				return -1
+			}
 			startAt++
 		}
 	}
-	return re.run(false, startAt, m.text)
+	return startAt
+}
+
+// CountString returns the number of matches in the input string, which is the
+// number of matches a FindStringMatch/FindNextMatch loop would find.  The matches
+// are found without allocating a Match for each one.
+// error will be set if a timeout occurs
+func (re *Regexp) CountString(s string) (int, error) {
+	return re.CountRunes(getRunes(s))
+}
+
+// CountRunes returns the number of matches in the input rune slice, which is the
+// number of matches a FindRunesMatch/FindNextMatch loop would find.  The matches
+// are found without allocating a Match for each one.
+// error will be set if a timeout occurs
+func (re *Regexp) CountRunes(r []rune) (int, error) {
+	runner := re.getRunner()
+	defer re.putRunner(runner)
+
+	return re.countRunes(runner, r)
+}
+
+func (re *Regexp) countRunes(runner *runner, r []rune) (int, error) {
+	textstart := 0
+	if re.RightToLeft() {
+		textstart = len(r)
+	}
+
+	count := 0
+	for textstart >= 0 {
+		// quick mode reuses the runner's Match for every search
+		m, err := runner.scan(r, textstart, -1, true, re.MatchTimeout)
+		if err != nil {
+			return 0, err
+		}
+		if m == nil {
+			break
+		}
+		count++
+		textstart = re.nextStart(runner.runtextpos, m.matches[0][1], 0, len(r))
+	}
+	return count, nil
 }
 
 // MatchString return true if the string matches the regex
@@ -217,6 +445,121 @@ func (re *Regexp) MatchString(s string) (bool, error) {
 	return m != nil, nil
 }
 
+// MatchAt returns the match starting exactly at the startAt index of the input string, or nil
+// if there isn't one.  No other positions are searched.  For RightToLeft patterns the match
+// ends at startAt instead.
+// error will be set if a timeout occurs
+func (re *Regexp) MatchAt(s string, startAt int) (*Match, error) {
+	if startAt > len(s) {
+		return nil, errors.New("startAt must be less than the length of the input string")
+	}
+	r, startAt := re.getRunesAndStart(s, startAt)
+	if startAt == -1 {
+		// we didn't find our start index in the string -- that's a problem
+		return nil, errors.New("startAt must align to the start of a valid rune in the input string")
+	}
+
+	return re.runAnchored(false, startAt, -1, r)
+}
+
+// MatchRunesAt returns the match starting exactly at the startAt index of the input rune
+// slice, or nil if there isn't one.  No other positions are searched.  For RightToLeft
+// patterns the match ends at startAt instead.
+// error will be set if a timeout occurs
+func (re *Regexp) MatchRunesAt(r []rune, startAt int) (*Match, error) {
+	if startAt < 0 || startAt > len(r) {
+		return nil, errors.New("startAt must be within the input")
+	}
+	return re.runAnchored(false, startAt, -1, r)
+}
+
+// FullMatchString returns true if the regex matches the entire string, like
+// wrapping the pattern in \A(?:...)\z but without changing how it's searched.
+// error will be set if a timeout occurs
+func (re *Regexp) FullMatchString(s string) (bool, error) {
+	return re.FullMatchRunes(getRunes(s))
+}
+
+// FullMatchRunes returns true if the regex matches the entire rune slice
+// error will be set if a timeout occurs
+func (re *Regexp) FullMatchRunes(r []rune) (bool, error) {
+	start, end := 0, len(r)
+	if re.RightToLeft() {
+		start, end = end, start
+	}
+	m, err := re.runAnchored(true, start, end, r)
+	if err != nil {
+		return false, err
+	}
+	return m != nil, nil
+}
+
+// FindAllMatchesAt returns every distinct way the regex can match starting exactly at the
+// startAt index of the input string, in the order backtracking finds them.  Matches are
+// distinct if any group captured different text, so the same overall match can appear
+// with different capture assignments.  This is meant for debugging ambiguous patterns.
+// At most max matches are returned, and max must be positive.
+// error will be set if a timeout occurs, along with the matches found before it
+func (re *Regexp) FindAllMatchesAt(s string, startAt int, max int) ([]*Match, error) {
+	if startAt > len(s) {
+		return nil, errors.New("startAt must be less than the length of the input string")
+	}
+	r, startAt := re.getRunesAndStart(s, startAt)
+	if startAt == -1 {
+		// we didn't find our start index in the string -- that's a problem
+		return nil, errors.New("startAt must align to the start of a valid rune in the input string")
+	}
+	return re.FindAllRunesMatchesAt(r, startAt, max)
+}
+
+// FindAllRunesMatchesAt is like FindAllMatchesAt but for a rune slice
+func (re *Regexp) FindAllRunesMatchesAt(r []rune, startAt int, max int) ([]*Match, error) {
+	if startAt < 0 || startAt > len(r) {
+		return nil, errors.New("startAt must be within the input")
+	}
+	if max < 1 {
+		return nil, errors.New("max must be positive")
+	}
+
+	runner := re.getRunner()
+	defer re.putRunner(runner)
+
+	runner.setInput(r, nil)
+	runner.runallmax = max
+	_, err := runner.scanText(startAt, startAt, true, re.MatchTimeout)
+	return runner.runall, err
+}
+
+// runAnchored looks for a match starting exactly at textstart, and ending exactly at
+// textend unless it's -1.  Nothing past textstart is searched.
+func (re *Regexp) runAnchored(quick bool, textstart, textend int, input []rune) (*Match, error) {
+	runner := re.getRunner()
+	defer re.putRunner(runner)
+
+	runner.setInput(input, nil)
+	runner.runmatchend = textend
+	return runner.scanText(textstart, textstart, quick, re.MatchTimeout)
+}
+
+// MatchReader returns true if the text read from the RuneReader contains a match of the regex.
+// Runes are only read as far as the runner needs them to decide.
+// error will be set if a timeout occurs or the reader fails
+func (re *Regexp) MatchReader(r io.RuneReader) (bool, error) {
+	loc, err := re.findReaderIndex(r)
+	if err != nil {
+		return false, err
+	}
+	return loc != nil, nil
+}
+
+// FindReaderIndex returns a two-element slice of integers defining the location of the
+// first match of the regex in the text read from the RuneReader.  Like the regexp package,
+// the match was found at byte offset loc[0] through loc[1]-1 of the input stream.
+// A nil slice means there was no match.  Runes are only read as far as the runner needs them.
+func (re *Regexp) FindReaderIndex(r io.RuneReader) (loc []int, err error) {
+	return re.findReaderIndex(r)
+}
+
 func (re *Regexp) getRunesAndStart(s string, startAt int) ([]rune, int) {
 	if startAt < 0 {
 		if re.RightToLeft() {
@@ -255,6 +598,33 @@ func (re *Regexp) MatchRunes(r []rune) (bool, error) {
 	return m != nil, nil
 }
 
+// MatchPartial returns true if the string matches the regex.  hitEnd reports whether the
+// search examined the end of s, in which case appending text to s could change the result.
+// When matched is false and hitEnd is true, s is the start of text that could still match,
+// which is what's needed to validate input as it's typed.
+// RightToLeft patterns aren't supported.
+// error will be set if a timeout occurs
+func (re *Regexp) MatchPartial(s string) (matched, hitEnd bool, err error) {
+	return re.MatchRunesPartial(getRunes(s))
+}
+
+// MatchRunesPartial is like MatchPartial but for a rune slice
+func (re *Regexp) MatchRunesPartial(r []rune) (matched, hitEnd bool, err error) {
+	if re.RightToLeft() {
+		return false, false, errors.New("regexp2: partial matching isn't supported for RightToLeft patterns")
+	}
+
+	runner := re.getRunner()
+	defer re.putRunner(runner)
+
+	runner.runtrackhitend = true
+	m, err := runner.scan(r, 0, -1, true, re.MatchTimeout)
+	if err != nil {
+		return false, false, err
+	}
+	return m != nil, runner.runhitend, nil
+}
+
 // GetGroupNames Returns the set of strings used to name capturing groups in the expression.
 func (re *Regexp) GetGroupNames() []string {
 	var result []string
@@ -294,6 +664,13 @@ func (re *Regexp) GetGroupNumbers() []int {
 	return result
 }
 
+// GroupNumbersByPosition returns the group numbers ordered by where each group opens
+// in the pattern, starting with 0 for the whole match.  Go's regexp package numbers
+// groups in this order, where named groups aren't numbered after the unnamed ones.
+func (re *Regexp) GroupNumbersByPosition() []int {
+	return append([]int(nil), re.capsorder...)
+}
+
 // GroupNameFromNumber retrieves a group name that corresponds to a group number.
 // It will return "" for and unknown group number.  Unnamed groups automatically
 // receive a name that is the decimal string equivalent of its number.
@@ -1,6 +1,7 @@
 package regexp2
 
 import (
+	"io"
 	"strings"
 	"testing"
 )
@@ -56,6 +57,43 @@ func BenchmarkMatchClass_InRange(b *testing.B) {
 	}
 }
 
+func BenchmarkMatchClass_Negated(b *testing.B) {
+	b.StopTimer()
+	x := strings.Repeat("abcd", 20) + "!"
+	re := MustCompile("[^a-z]", 0)
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		if m, err := re.MatchString(x); !m || err != nil {
+			b.Fatalf("no match or error! %v", err)
+		}
+	}
+}
+
+func BenchmarkMatchClass_Category(b *testing.B) {
+	b.StopTimer()
+	// \w and \s need unicode tables for every char without the ascii lookup
+	x := strings.Repeat("abc ", 20) + "-"
+	re := MustCompile(`[^\w\s]`, 0)
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		if m, err := re.MatchString(x); !m || err != nil {
+			b.Fatalf("no match or error! %v", err)
+		}
+	}
+}
+
+func BenchmarkMatchClass_Loop(b *testing.B) {
+	b.StopTimer()
+	x := strings.Repeat("word_42 ", 20) + "!"
+	re := MustCompile(`[\w\s]+!`, 0)
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		if m, err := re.MatchString(x); !m || err != nil {
+			b.Fatalf("no match or error! %v", err)
+		}
+	}
+}
+
 /*
 func BenchmarkReplaceAll(b *testing.B) {
 	x := "abcdefghijklmnopqrstuvwxyz"
@@ -305,3 +343,39 @@ func BenchmarkLeading(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkCountRunes(b *testing.B) {
+	b.StopTimer()
+	r := []rune(strings.Repeat("the quick brown fox jumps over the lazy dog ", 1000))
+	re := MustCompile(`\b(\w)(\w+)\b`, 0)
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := re.CountRunes(r); err != nil {
+			b.Fatalf("Unexpected err: %v", err)
+		}
+	}
+}
+
+func BenchmarkReplaceShort(b *testing.B) {
+	b.StopTimer()
+	x := "abcdefghijklmnopqrstuvwxyz"
+	re := MustCompile("[cjrw]", 0)
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := re.Replace(x, "<$0>", -1, -1); err != nil {
+			b.Fatalf("Unexpected err: %v", err)
+		}
+	}
+}
+
+func BenchmarkReplaceTo(b *testing.B) {
+	b.StopTimer()
+	x := strings.Repeat("the quick brown fox jumps over the lazy dog ", 1000)
+	re := MustCompile(`\b(\w)(\w+)\b`, 0)
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		if err := re.ReplaceTo(io.Discard, x, "$2$1ay", -1, -1); err != nil {
+			b.Fatalf("Unexpected err: %v", err)
+		}
+	}
+}
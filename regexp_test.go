@@ -1,7 +1,10 @@
 package regexp2
 
 import (
+	"fmt"
+	"math"
 	"reflect"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -545,6 +548,35 @@ func TestUnicodeSupplementaryCharInRange(t *testing.T) {
 	}
 }
 
+func TestCharSetASCIILookupBoundaries(t *testing.T) {
+	tests := []struct {
+		pattern string
+		input   string
+		want    bool
+	}{
+		{`^[\x00-\x7f]$`, "\x7f", true},
+		{`^[\x00-\x7f]$`, "\u0080", false},
+		{`^[^\x00-\x7f]$`, "\u0080", true},
+		{`^[^a-z]$`, "𠜱", true},
+		{`^[^\x00-\x60\x7b-\x7f]$`, "𠜱", true},
+		{`^[^a-z-[\u0100-\u01ff]]$`, "\u00ff", true},
+		{`^[^a-z-[\u0100-\u01ff]]$`, "\u0150", false},
+		{`^[^a-z-[\u0100-\u01ff]]$`, "A", true},
+		{`^[\w-[\d]]$`, "\u00e9", true},
+		{`^[\w-[\d]]$`, "5", false},
+		{`^[^\s]$`, "\u2003", false},
+	}
+
+	for _, test := range tests {
+		re := MustCompile(test.pattern, 0)
+		if m, err := re.MatchString(test.input); err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		} else if m != test.want {
+			t.Errorf("%v on %q: wanted %v, got %v", test.pattern, test.input, test.want, m)
+		}
+	}
+}
+
 func TestUnicodeScriptSets(t *testing.T) {
 	re := MustCompile(`\p{Katakana}+`, 0)
 	if m, err := re.MatchString("\u30A0\u30FF"); err != nil {
@@ -1220,3 +1252,585 @@ func TestFuzzBytes_Match(t *testing.T) {
 		})
 	}
 }
+
+func TestRunnerTrim_OversizedStacks(t *testing.T) {
+	re := MustCompile(`(a)*b`, 0)
+	input := []rune(strings.Repeat("a", maxPooledStackSize) + "b")
+
+	r := re.getRunner()
+	m, err := r.scan(input, 0, -1, false, DefaultMatchTimeout)
+	if err != nil {
+		t.Fatalf("Unexpected err: %v", err)
+	}
+	if m == nil {
+		t.Fatalf("Expected match")
+	}
+	if len(r.runtrack) <= maxPooledStackSize {
+		t.Fatalf("Expected backtracking stack to grow past %v, got %v", maxPooledStackSize, len(r.runtrack))
+	}
+
+	r.trim()
+	if r.runtrack != nil || r.runstack != nil || r.runcrawl != nil {
+		t.Fatalf("Expected oversized stacks to be released")
+	}
+	if r.runtext != nil {
+		t.Fatalf("Expected input text to be released")
+	}
+
+	// the trimmed runner must still be usable
+	m, err = r.scan([]rune("aab"), 0, -1, false, DefaultMatchTimeout)
+	if err != nil {
+		t.Fatalf("Unexpected err: %v", err)
+	}
+	if want, got := "aab", m.String(); want != got {
+		t.Fatalf("Wanted '%v'\nGot '%v'", want, got)
+	}
+	re.putRunner(r)
+}
+
+func TestRunnerTrim_KeepsSmallStacks(t *testing.T) {
+	re := MustCompile(`(a)*b`, 0)
+	r := re.getRunner()
+	if _, err := r.scan([]rune("aab"), 0, -1, true, DefaultMatchTimeout); err != nil {
+		t.Fatalf("Unexpected err: %v", err)
+	}
+	track := r.runtrack
+
+	r.trim()
+	if len(r.runtrack) != len(track) || r.runcrawl == nil {
+		t.Fatalf("Expected small stacks to be kept for reuse")
+	}
+	if r.runmatch == nil || r.runmatch.text != nil {
+		t.Fatalf("Expected cached match to drop its text")
+	}
+}
+
+func TestPutRunner_Bounded(t *testing.T) {
+	re := MustCompile(`a`, 0)
+	runners := make([]*runner, maxPooledRunners+4)
+	for i := range runners {
+		runners[i] = re.getRunner()
+	}
+	for _, r := range runners {
+		re.putRunner(r)
+	}
+	if want, got := maxPooledRunners, len(re.runner); want != got {
+		t.Fatalf("Wanted '%v' idle runners\nGot '%v'", want, got)
+	}
+
+	re.ReleaseMemory()
+	if len(re.runner) != 0 {
+		t.Fatalf("Expected ReleaseMemory to drop the idle runners, %v left", len(re.runner))
+	}
+}
+
+func TestReleaseMemory(t *testing.T) {
+	re := MustCompile(`\d+`, 0)
+	for i := 0; i < 2; i++ {
+		if m, err := re.MatchString("abc123"); err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		} else if !m {
+			t.Fatalf("Expected match")
+		}
+		re.ReleaseMemory()
+	}
+}
+
+func TestMatchHitEnd(t *testing.T) {
+	tests := []struct {
+		pattern, input string
+		hitEnd         bool
+	}{
+		{`abc`, "xabcx", false},
+		{`abc`, "xabc", false},
+		{`\d+`, "a123", true},
+		{`\d+`, "a123b", false},
+		{`\d{2}`, "a123", false},
+		{`cat|category`, "cat", false},
+		{`category|cat`, "cat", true},
+		{`abc$`, "abc", true},
+		{`abc\b`, "abc", true},
+		{`abc\b`, "abc!", false},
+		{`a(?=bc)`, "abc", false},
+		{`a(?=b\w*)`, "xxabc", true},
+	}
+
+	for _, test := range tests {
+		m, err := MustCompile(test.pattern, 0).FindStringMatch(test.input)
+		if err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+		if m == nil {
+			t.Fatalf("pattern %q: expected a match in %q", test.pattern, test.input)
+		}
+		if want, got := test.hitEnd, m.HitEnd(); want != got {
+			t.Fatalf("pattern %q input %q: Wanted '%v'\nGot '%v'", test.pattern, test.input, want, got)
+		}
+	}
+}
+
+func TestMatchPartial(t *testing.T) {
+	re := MustCompile(`^\d{3}-\d{4}$`, 0)
+	tests := []struct {
+		input           string
+		matched, hitEnd bool
+	}{
+		{"", false, true},
+		{"5", false, true},
+		{"555-", false, true},
+		{"555-12", false, true},
+		{"555-1234", true, true},
+		{"555-12345", false, false},
+		{"55a", false, false},
+		{"555-1a", false, false},
+	}
+
+	for _, test := range tests {
+		matched, hitEnd, err := re.MatchPartial(test.input)
+		if err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+		if matched != test.matched || hitEnd != test.hitEnd {
+			t.Fatalf("input %q: Wanted '%v %v'\nGot '%v %v'", test.input, test.matched, test.hitEnd, matched, hitEnd)
+		}
+	}
+
+	if _, _, err := MustCompile(`a`, RightToLeft).MatchPartial("a"); err == nil {
+		t.Fatalf("Expected an error for a RightToLeft pattern")
+	}
+}
+
+func TestMatchPartial_Repeat(t *testing.T) {
+	tests := []struct {
+		pattern, input  string
+		matched, hitEnd bool
+	}{
+		{`^a{3}b`, "aa", false, true},
+		{`^a{3}b`, "ax", false, false},
+		{`^[^x]{3}`, "ab", false, true},
+		{`^[^x]{3}`, "xb", false, false},
+		{`^\d{3}`, "1a", false, false},
+		{`^abc`, "ab", false, true},
+		{`^abc`, "ax", false, false},
+		{`^(ab)\1`, "aba", false, true},
+		{`^(ab)\1`, "abx", false, false},
+	}
+
+	for _, test := range tests {
+		matched, hitEnd, err := MustCompile(test.pattern, 0).MatchPartial(test.input)
+		if err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+		if matched != test.matched || hitEnd != test.hitEnd {
+			t.Fatalf("pattern %q input %q: Wanted '%v %v'\nGot '%v %v'", test.pattern, test.input, test.matched, test.hitEnd, matched, hitEnd)
+		}
+	}
+}
+
+func TestRepeatLongerThanText(t *testing.T) {
+	// a repeat that can't fit in the rest of the text fails without reading it,
+	// otherwise every start position costs the length of the text
+	re := MustCompile("\r{100001}T+", 0)
+	re.MatchTimeout = 5 * time.Second
+	inp := strings.Repeat("testing", 10000) + strings.Repeat("\r", 100000) + "TTTT"
+
+	if m, err := re.MatchString(inp); err != nil {
+		t.Fatalf("Unexpected err: %v", err)
+	} else if m {
+		t.Fatalf("Expected no match")
+	}
+}
+
+func TestMatchAt(t *testing.T) {
+	tests := []struct {
+		pattern string
+		opt     RegexOptions
+		input   string
+		pos     int
+		want    string // "-" for no match
+	}{
+		{`\d+`, 0, "ab 123 45", 3, "123"},
+		{`\d+`, 0, "ab 123 45", 4, "23"},
+		{`\d+`, 0, "ab 123 45", 2, "-"},
+		{`45`, 0, "ab 123 45", 0, "-"},
+		{`45`, 0, "ab 123 45", 7, "45"},
+		{`(?i)AB`, 0, "xab", 1, "ab"},
+		{`^\d`, Multiline, "ab\n12", 3, "1"},
+		{`^\d`, Multiline, "ab\n12", 4, "-"},
+		{`^\d`, 0, "ab\n12", 3, "-"},
+		{`\d$`, Multiline, "a1\nb2", 1, "1"},
+		{`\d+`, RightToLeft, "ab 123 45", 6, "123"},
+		{`\d+`, RightToLeft, "ab 123 45", 5, "12"},
+		{`\d+`, RightToLeft, "ab 123 45", 7, "-"},
+		{`\G\w`, 0, "abc", 2, "c"},
+		{`(?<=a)b`, 0, "abab", 3, "b"},
+		{`(?<=a)b`, 0, "abab", 2, "-"},
+		{``, 0, "abc", 3, ""},
+	}
+
+	for _, test := range tests {
+		re := MustCompile(test.pattern, test.opt)
+		m, err := re.MatchAt(test.input, test.pos)
+		if err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+		got := "-"
+		if m != nil {
+			got = m.String()
+		}
+		if want := test.want; want != got {
+			t.Fatalf("pattern %q at %v: Wanted '%v'\nGot '%v'", test.pattern, test.pos, want, got)
+		}
+	}
+
+	if _, err := MustCompile(`a`, 0).MatchAt("día", 2); err == nil {
+		t.Fatalf("Expected an error for a position inside a rune")
+	}
+	if _, err := MustCompile(`a`, 0).MatchRunesAt([]rune("a"), 2); err == nil {
+		t.Fatalf("Expected an error for a position past the end")
+	}
+}
+
+func TestFullMatchString(t *testing.T) {
+	tests := []struct {
+		pattern string
+		opt     RegexOptions
+		input   string
+		want    bool
+	}{
+		{`\d+`, 0, "123", true},
+		{`\d+`, 0, "123a", false},
+		{`\d+`, 0, "a123", false},
+		{`a|ab`, 0, "ab", true},
+		{`a+?`, 0, "aaa", true},
+		{`\d+`, RightToLeft, "123", true},
+		{`\d+`, RightToLeft, "a123", false},
+		{`a|ab`, RightToLeft, "ab", true},
+		{`^\w+$`, Multiline, "ab\ncd", false},
+		{`\w+\n\w+`, Multiline, "ab\ncd", true},
+		{`(?>a+)b?`, 0, "aab", true},
+		{`(?>a+)`, 0, "aab", false},
+		{`abc$`, 0, "abc\n", false},
+		{``, 0, "", true},
+		{``, 0, "a", false},
+	}
+
+	for _, test := range tests {
+		got, err := MustCompile(test.pattern, test.opt).FullMatchString(test.input)
+		if err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+		if want := test.want; want != got {
+			t.Fatalf("pattern %q input %q: Wanted '%v'\nGot '%v'", test.pattern, test.input, want, got)
+		}
+	}
+}
+
+func TestCountString(t *testing.T) {
+	input := "the cat sat on the mat with a hat\nfin"
+	tests := []struct {
+		pattern string
+		opt     RegexOptions
+	}{
+		{`\wat`, 0},
+		{`\wat`, RightToLeft},
+		{`the`, IgnoreCase},
+		{``, 0},
+		{``, RightToLeft},
+		{`x*`, 0},
+		{`^\w+`, Multiline},
+		{`\b`, 0},
+		{`zzz`, 0},
+	}
+
+	for _, test := range tests {
+		re := MustCompile(test.pattern, test.opt)
+		all, err := re.findAllSequential([]rune(input))
+		if err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+
+		got, err := re.CountString(input)
+		if err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+		if want := len(all); want != got {
+			t.Fatalf("pattern %q: Wanted '%v'\nGot '%v'", test.pattern, want, got)
+		}
+	}
+}
+
+func TestCountRunes_NoAllocPerMatch(t *testing.T) {
+	re := MustCompile(`(\w)(\w)`, 0)
+	short := []rune("ab cd")
+	long := []rune(strings.Repeat("ab cd ", 100))
+
+	// hold the runner rather than use the pool, and warm it up so its Match
+	// is already allocated
+	r := re.getRunner()
+	re.countRunes(r, long)
+
+	allocsShort := testing.AllocsPerRun(10, func() { re.countRunes(r, short) })
+	allocsLong := testing.AllocsPerRun(10, func() { re.countRunes(r, long) })
+	if allocsLong > allocsShort {
+		t.Fatalf("Allocations grew with the number of matches: %v for 2 matches, %v for 200", allocsShort, allocsLong)
+	}
+}
+
+func TestFindNextOverlappingMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		opt     RegexOptions
+		input   string
+		want    string
+	}{
+		{`aa`, 0, "aaaa", "[aa@0 aa@1 aa@2]"},
+		{`aa`, RightToLeft, "aaaa", "[aa@2 aa@1 aa@0]"},
+		{`a+`, 0, "aaab", "[aaa@0 aa@1 a@2]"},
+		{`a+`, RightToLeft, "baaa", "[aaa@1 aa@1 a@1]"},
+		{`ATG(?:...)*?(?:TAA|TAG)`, 0, "ATGATGTAAG", "[ATGATGTAA@0 ATGTAA@3]"},
+		{`a*`, 0, "ab", "[a@0 @1 @2]"},
+		{`(?<=a)b`, 0, "abab", "[b@1 b@3]"},
+	}
+
+	for _, test := range tests {
+		re := MustCompile(test.pattern, test.opt)
+		var got []string
+		m, err := re.FindStringMatch(test.input)
+		for m != nil {
+			got = append(got, fmt.Sprintf("%v@%v", m.String(), m.Index))
+			m, err = re.FindNextOverlappingMatch(m)
+		}
+		if err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+		if want, got := test.want, fmt.Sprint(got); want != got {
+			t.Fatalf("pattern %q: Wanted '%v'\nGot '%v'", test.pattern, want, got)
+		}
+	}
+}
+
+func TestFindNextOverlappingMatch_Groups(t *testing.T) {
+	re := MustCompile(`(?<first>\w)(?<rest>\w+)`, 0)
+	m, err := re.FindStringMatch("abc")
+	var got []string
+	for m != nil {
+		got = append(got, m.GroupByName("first").String()+"|"+m.GroupByName("rest").String())
+		m, err = re.FindNextOverlappingMatch(m)
+	}
+	if err != nil {
+		t.Fatalf("Unexpected err: %v", err)
+	}
+	if want, got := "[a|bc b|c]", fmt.Sprint(got); want != got {
+		t.Fatalf("Wanted '%v'\nGot '%v'", want, got)
+	}
+}
+
+func TestFindAllMatchesAt(t *testing.T) {
+	tests := []struct {
+		pattern string
+		input   string
+		startAt int
+		max     int
+		want    string
+	}{
+		{`a+`, "aaa", 0, 10, "[aaa aa a]"},
+		{`a+?`, "aaa", 0, 10, "[a aa aaa]"},
+		{`a+`, "aaa", 1, 10, "[aa a]"},
+		{`a+`, "aaa", 0, 2, "[aaa aa]"},
+		{`(a|ab)(c|bcd)`, "abcd", 0, 10, "[abcd(a,bcd) abc(ab,c)]"},
+		{`(a*)(a*)`, "aa", 0, 10, "[aa(aa,) aa(a,a) a(a,) aa(,aa) a(,a) (,)]"},
+		{`(a|a)`, "a", 0, 10, "[a(a)]"},
+		{`b`, "ab", 0, 10, "[]"},
+	}
+
+	for _, test := range tests {
+		re := MustCompile(test.pattern, 0)
+		ms, err := re.FindAllMatchesAt(test.input, test.startAt, test.max)
+		if err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+
+		var got []string
+		for _, m := range ms {
+			s := m.String()
+			if gs := m.Groups(); len(gs) > 1 {
+				var caps []string
+				for _, g := range gs[1:] {
+					caps = append(caps, g.String())
+				}
+				s += "(" + strings.Join(caps, ",") + ")"
+			}
+			got = append(got, s)
+		}
+		if want, got := test.want, fmt.Sprint(got); want != got {
+			t.Fatalf("pattern %q: Wanted '%v'\nGot '%v'", test.pattern, want, got)
+		}
+	}
+
+	if _, err := MustCompile(`a`, 0).FindAllMatchesAt("a", 0, 0); err == nil {
+		t.Fatalf("Expected an error for a non-positive max")
+	}
+}
+
+func TestFindAllMatchesAt_Timeout(t *testing.T) {
+	re := MustCompile(`(a+)+b?`, 0)
+	re.MatchTimeout = time.Millisecond * 10
+	_, err := re.FindAllMatchesAt(strings.Repeat("a", 40), 0, math.MaxInt32)
+	if err == nil {
+		t.Fatalf("Expected a timeout")
+	}
+}
+
+func TestLongest(t *testing.T) {
+	tests := []struct {
+		pattern string
+		opt     RegexOptions
+		input   string
+		want    string
+	}{
+		{`a|ab`, 0, "abc", "ab"},
+		{`a+?`, 0, "aaa", "aaa"},
+		{`b+|a`, 0, "xabbb", "a"},
+		{`(a|ab)(c|bcd)(d*)`, 0, "abcd", "abcd(ab,c,d)"},
+		{`(a*)(a*)`, 0, "aa", "aa(aa,)"},
+		{`(a|b)?(ab)?`, 0, "ab", "ab(,ab)"},
+		{`(a?)(a?b)`, 0, "ab", "ab(a,b)"},
+		{`(a??)(a?b)`, 0, "ab", "ab(a,b)"},
+		{`(x)?(a|ab)`, 0, "ab", "ab(,ab)"},
+		{`b|ab`, RightToLeft, "ab", "ab"},
+		{`\d+?$`, Multiline, "12\n34", "12"},
+	}
+
+	for _, test := range tests {
+		re := MustCompile(test.pattern, test.opt)
+		re.Longest()
+		m, err := re.FindStringMatch(test.input)
+		if err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+
+		got := m.String()
+		if gs := m.Groups(); len(gs) > 1 {
+			var caps []string
+			for _, g := range gs[1:] {
+				caps = append(caps, g.String())
+			}
+			got += "(" + strings.Join(caps, ",") + ")"
+		}
+		if want := test.want; want != got {
+			t.Fatalf("pattern %q: Wanted '%v'\nGot '%v'", test.pattern, want, got)
+		}
+	}
+}
+
+func TestLongest_SameAsStdlib(t *testing.T) {
+	patterns := []string{`a|ab|abc`, `(a|ab)(c|bcd)`, `x+|xy`, `[a-c]+?`, `(?:ab)*?a`, `\w+?\s|\w+`}
+	inputs := []string{"abcd", "xyz ab abc", "zzz", "ab abab a", "aaa bbb"}
+
+	for _, p := range patterns {
+		std := regexp.MustCompile(p)
+		std.Longest()
+		re := MustCompile(p, 0)
+		re.Longest()
+
+		for _, in := range inputs {
+			want := std.FindAllString(in, -1)
+			var got []string
+			m, err := re.FindStringMatch(in)
+			for m != nil {
+				got = append(got, m.String())
+				m, err = re.FindNextMatch(m)
+			}
+			if err != nil {
+				t.Fatalf("Unexpected err: %v", err)
+			}
+			if fmt.Sprint(want) != fmt.Sprint(got) {
+				t.Fatalf("pattern %q input %q: Wanted '%v'\nGot '%v'", p, in, want, got)
+			}
+		}
+	}
+}
+
+func TestGroupNumbersByPosition(t *testing.T) {
+	for _, tt := range []struct {
+		p    string
+		want []int
+	}{
+		{`a`, []int{0}},
+		{`(a)(b)`, []int{0, 1, 2}},
+		{`(?<x>a)(b)`, []int{0, 2, 1}},
+		{`(a(?<x>b)(c))(d)`, []int{0, 1, 4, 2, 3}},
+		{`(?<5>a)(b)`, []int{0, 5, 1}},
+		{`(?(a)b|c)(d)`, []int{0, 1}},
+	} {
+		re := MustCompile(tt.p, 0)
+		if got := re.GroupNumbersByPosition(); !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("%v: Wanted '%v'\nGot '%v'", tt.p, tt.want, got)
+		}
+	}
+}
+
+func TestGroupSuccess(t *testing.T) {
+	re := MustCompile(`(a)?(?<e>b*)c`, 0)
+	m, err := re.FindStringMatch("xc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !m.Success {
+		t.Fatal("Expected the match to succeed")
+	}
+	if g := m.GroupByNumber(1); g.Success || len(g.Captures) != 0 || g.Index != 0 || g.Length != 0 {
+		t.Fatalf("Expected group 1 not to participate, got %+v", *g)
+	}
+	// an empty capture still participated
+	if g := m.GroupByName("e"); !g.Success || len(g.Captures) != 1 || g.Index != 1 || g.Length != 0 {
+		t.Fatalf("Expected group e to participate, got %+v", *g)
+	}
+	if want, got := []bool{true, false, true}, []bool{m.Groups()[0].Success, m.Groups()[1].Success, m.Groups()[2].Success}; !reflect.DeepEqual(want, got) {
+		t.Fatalf("Wanted '%v'\nGot '%v'", want, got)
+	}
+	if g := m.GroupByName("x"); g != nil {
+		t.Fatalf("Expected nil for an unknown group name, got %+v", *g)
+	}
+	if g := m.GroupByNumber(3); g != nil {
+		t.Fatalf("Expected nil for an unknown group number, got %+v", *g)
+	}
+}
+
+func TestGroupByNumber_Sparse(t *testing.T) {
+	re := MustCompile(`(?<5>a)(b)?(?<x>c)`, 0)
+	m, err := re.FindStringMatch("ac")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		num     int
+		name    string
+		success bool
+	}{
+		{0, "0", true},
+		{1, "1", false},
+		{2, "x", true},
+		{5, "5", true},
+	} {
+		g := m.GroupByNumber(tt.num)
+		if g == nil {
+			t.Fatalf("Expected group %v", tt.num)
+		}
+		if g.Name != tt.name || g.Success != tt.success {
+			t.Errorf("Group %v: Wanted '%v %v'\nGot '%v %v'", tt.num, tt.name, tt.success, g.Name, g.Success)
+		}
+		if n := m.GroupByName(tt.name); n != g {
+			t.Errorf("Group %v: GroupByName %q returned a different group", tt.num, tt.name)
+		}
+	}
+	// numbers between the sparse ones don't exist
+	for _, num := range []int{3, 4, 6} {
+		if g := m.GroupByNumber(num); g != nil {
+			t.Errorf("Expected nil for group %v, got %+v", num, *g)
+		}
+	}
+}
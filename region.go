@@ -0,0 +1,91 @@
+package regexp2
+
+import "errors"
+
+// RegionBounds tells if anchors, word boundaries and lookarounds can see the
+// text around a region the search is restricted to
+type RegionBounds int
+
+const (
+	// OpaqueBounds makes the region behave as if it were the whole input.  This is
+	// the same as searching a slice of the input, except that positions are relative
+	// to all of it.
+	OpaqueBounds RegionBounds = iota
+	// TransparentBounds lets ^, $, \b and lookarounds see the text around the region,
+	// so for example \b doesn't match at the edge of the region in the middle of a word.
+	// Matches still lie within the region.
+	TransparentBounds
+)
+
+// region restricts a search to [beg, end) of the text
+type region struct {
+	beg, end int
+	bounds   RegionBounds
+}
+
+// FindStringMatchInRegion searches the part of the input string from byte offset start
+// up to end for a Regexp match.  Match positions are relative to all of s, and
+// FindNextMatch continues searching the same region.
+func (re *Regexp) FindStringMatchInRegion(s string, start, end int, bounds RegionBounds) (*Match, error) {
+	if start < 0 || end < start || end > len(s) {
+		return nil, errors.New("region must be within the input string")
+	}
+	r, start, end := getRunesAndRegion(s, start, end)
+	if start == -1 || end == -1 {
+		return nil, errors.New("region must align to the start of valid runes in the input string")
+	}
+	return re.runRegion(false, -1, r, nil, &region{beg: start, end: end, bounds: bounds})
+}
+
+// FindRunesMatchInRegion searches r[start:end] for a Regexp match.  Match positions are
+// relative to all of r, and FindNextMatch continues searching the same region.
+func (re *Regexp) FindRunesMatchInRegion(r []rune, start, end int, bounds RegionBounds) (*Match, error) {
+	if start < 0 || end < start || end > len(r) {
+		return nil, errors.New("region must be within the input")
+	}
+	return re.runRegion(false, -1, r, nil, &region{beg: start, end: end, bounds: bounds})
+}
+
+// runRegion is like run, but matches must lie in the region
+func (re *Regexp) runRegion(quick bool, textstart int, rt []rune, in Input, reg *region) (*Match, error) {
+	runner := re.getRunner()
+	defer re.putRunner(runner)
+
+	if textstart < 0 {
+		if re.RightToLeft() {
+			textstart = reg.end
+		} else {
+			textstart = reg.beg
+		}
+	}
+
+	runner.setInput(rt, in)
+	runner.setRegion(reg.beg, reg.end, reg.bounds == TransparentBounds)
+	runner.runregion = reg
+	return runner.scanText(textstart, -1, quick, re.MatchTimeout)
+}
+
+// getRunesAndRegion converts s to runes and the byte offsets start and end
+// to rune indexes, which are -1 if they aren't at the start of a rune
+func getRunesAndRegion(s string, start, end int) ([]rune, int, int) {
+	ret := make([]rune, len(s))
+	i := 0
+	runeStart, runeEnd := -1, -1
+	for strIdx, r := range s {
+		if strIdx == start {
+			runeStart = i
+		}
+		if strIdx == end {
+			runeEnd = i
+		}
+		ret[i] = r
+		i++
+	}
+	if start == len(s) {
+		runeStart = i
+	}
+	if end == len(s) {
+		runeEnd = i
+	}
+	return ret[:i], runeStart, runeEnd
+}
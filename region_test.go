@@ -0,0 +1,146 @@
+package regexp2
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRegion_OpaqueSameAsSlice(t *testing.T) {
+	input := []rune("foo bar\nbaz qux\nquux")
+	patterns := []string{`\b\w+\b`, `^\w`, `\w$`, `(?<=a)\w`, `\w(?=u)`, `(?m)^\w+$`, `\Aq\w*`, `\w+\z`, `a|`, `\w*`}
+
+	for _, p := range patterns {
+		for _, opt := range []RegexOptions{0, RightToLeft} {
+			re := MustCompile(p, opt)
+			for start := 0; start <= len(input); start += 3 {
+				for end := start; end <= len(input); end += 4 {
+					want, err := re.findAllSequential(input[start:end])
+					if err != nil {
+						t.Fatalf("Unexpected err: %v", err)
+					}
+
+					var got []*Match
+					m, err := re.FindRunesMatchInRegion(input, start, end, OpaqueBounds)
+					for m != nil {
+						got = append(got, m)
+						m, err = re.FindNextMatch(m)
+					}
+					if err != nil {
+						t.Fatalf("Unexpected err: %v", err)
+					}
+
+					if len(want) != len(got) {
+						t.Fatalf("pattern %q region [%v,%v): Wanted %v matches\nGot %v", p, start, end, len(want), len(got))
+					}
+					for i := range want {
+						if want[i].Index+start != got[i].Index || want[i].String() != got[i].String() {
+							t.Fatalf("pattern %q region [%v,%v): Wanted '%v' at %v\nGot '%v' at %v",
+								p, start, end, want[i].String(), want[i].Index+start, got[i].String(), got[i].Index)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestRegion_Bounds(t *testing.T) {
+	// the region is "cat" in the middle of "concatenate"
+	input := []rune("concatenate")
+
+	tests := []struct {
+		pattern     string
+		opt         RegexOptions
+		opaque      string
+		transparent string
+	}{
+		{`\bcat\b`, 0, "cat", ""},
+		{`\Bcat\B`, 0, "", "cat"},
+		{`(?<=con)cat`, 0, "", "cat"},
+		{`(?<!con)cat`, 0, "cat", ""},
+		{`cat(?=enate)`, 0, "", "cat"},
+		{`cat(?!e)`, 0, "cat", ""},
+		{`^cat`, 0, "cat", ""},
+		{`cat$`, 0, "cat", ""},
+		{`\Acat\z`, 0, "cat", ""},
+		{`cat\w*`, 0, "cat", "cat"},
+		{`\w*cat`, 0, "cat", "cat"},
+		{`\w*cat`, RightToLeft, "cat", "cat"},
+		{`(?<=con)cat`, RightToLeft, "", "cat"},
+		{`\bcat\b`, RightToLeft, "cat", ""},
+		{`cat(?=e)`, RightToLeft, "", "cat"},
+		{`\w+(?<=n\w*)`, 0, "", "cat"},
+		// leaving a lookaround by failing or backtracking restores the region's bounds
+		{`(?=catx)|cat\w*`, 0, "cat", "cat"},
+		{`(?!cate)\w+|cat\w*`, 0, "cat", "cat"},
+		{`cat(?=(?>x)|e)\w*`, 0, "", "cat"},
+		{`cat(?=(?>en)ate)\w*`, 0, "", "cat"},
+		{`(?>(?=cate)cat\w*)`, 0, "", "cat"},
+	}
+
+	for _, test := range tests {
+		re := MustCompile(test.pattern, test.opt)
+		for _, bounds := range []RegionBounds{OpaqueBounds, TransparentBounds} {
+			want := test.opaque
+			if bounds == TransparentBounds {
+				want = test.transparent
+			}
+
+			m, err := re.FindRunesMatchInRegion(input, 3, 6, bounds)
+			if err != nil {
+				t.Fatalf("Unexpected err: %v", err)
+			}
+			got := ""
+			if m != nil {
+				got = m.String()
+				if m.Index != 3 {
+					t.Fatalf("pattern %q bounds %v: Wanted match at 3\nGot %v", test.pattern, bounds, m.Index)
+				}
+			}
+			if want != got {
+				t.Fatalf("pattern %q bounds %v: Wanted '%v'\nGot '%v'", test.pattern, bounds, want, got)
+			}
+		}
+	}
+}
+
+func TestRegion_String(t *testing.T) {
+	s := "día 12 año 345 mes 6"
+	re := MustCompile(`\d+`, 0)
+
+	// byte offsets of "año 345"
+	m, err := re.FindStringMatchInRegion(s, 8, 17, TransparentBounds)
+	if err != nil {
+		t.Fatalf("Unexpected err: %v", err)
+	}
+	var got []string
+	for m != nil {
+		got = append(got, fmt.Sprintf("%v@%v", m.String(), m.Index))
+		m, err = re.FindNextMatch(m)
+	}
+	if err != nil {
+		t.Fatalf("Unexpected err: %v", err)
+	}
+	if want, got := "[345@11]", fmt.Sprint(got); want != got {
+		t.Fatalf("Wanted '%v'\nGot '%v'", want, got)
+	}
+
+	// ending the region part way through "345" with transparent bounds still can't match past it
+	m, err = re.FindStringMatchInRegion(s, 8, 15, TransparentBounds)
+	if err != nil {
+		t.Fatalf("Unexpected err: %v", err)
+	}
+	if want, got := "34", m.String(); want != got {
+		t.Fatalf("Wanted '%v'\nGot '%v'", want, got)
+	}
+
+	if _, err := re.FindStringMatchInRegion(s, 2, 5, OpaqueBounds); err == nil {
+		t.Fatalf("Expected an error for a region inside a rune")
+	}
+	if _, err := re.FindStringMatchInRegion(s, 5, 2, OpaqueBounds); err == nil {
+		t.Fatalf("Expected an error for an inverted region")
+	}
+	if _, err := re.FindRunesMatchInRegion([]rune(s), 0, 100, OpaqueBounds); err == nil {
+		t.Fatalf("Expected an error for a region past the end")
+	}
+}
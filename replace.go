@@ -1,23 +1,107 @@
 package regexp2
 
 import (
-	"bytes"
 	"errors"
+	"io"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/dlclark/regexp2/syntax"
 )
 
 const (
-	replaceSpecials     = 4
+	replaceSpecials     = 11
 	replaceLeftPortion  = -1
 	replaceRightPortion = -2
 	replaceLastGroup    = -3
 	replaceWholeString  = -4
+	replaceUpperCase    = -5
+	replaceLowerCase    = -6
+	replaceTitleNext    = -7
+	replaceLowerNext    = -8
+	replaceEndCase      = -9
+
+	replaceLastMatchedGroup = -10
+	replaceConditional      = -11
 )
 
+// maxCachedReplacers is the number of parsed replacement patterns each Regexp keeps for Replace
+const maxCachedReplacers = 16
+
+// Replacer is a replacement pattern compiled for a Regexp, so that it can be applied
+// many times without being parsed again.  It's also a Template for the pattern.
+// A Replacer is safe for concurrent use by multiple goroutines.
+type Replacer struct {
+	*Template
+}
+
+// CompileReplacement parses a replacement pattern for use with re, with the syntax
+// selected by opts.  Errors in the pattern are reported here rather than when it's applied.
+func (re *Regexp) CompileReplacement(rep string, opts ReplaceOptions) (*Replacer, error) {
+	data, err := re.replacerData(rep, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Replacer{&Template{re: re, data: data}}, nil
+}
+
+// Replace searches the input string and replaces each match found with the replacement,
+// exactly like Regexp.ReplaceWithOptions with the pattern and options the Replacer was
+// compiled from.
+func (r *Replacer) Replace(input string, startAt, count int) (string, error) {
+	return replace(r.re, r.data, nil, input, startAt, count)
+}
+
+// ReplaceTo is like Replace but writes the result to w, like Regexp.ReplaceTo
+func (r *Replacer) ReplaceTo(w io.Writer, input string, startAt, count int) error {
+	return replaceTo(w, r.re, r.data, nil, input, startAt, count)
+}
+
+// replacerKey identifies a parsed replacement pattern in the cache
+type replacerKey struct {
+	rep     string
+	options ReplaceOptions
+}
+
+// replacerData returns the parsed replacement pattern, from the cache if it was
+// used recently
+func (re *Regexp) replacerData(rep string, opts ReplaceOptions) (*syntax.ReplacerData, error) {
+	key := replacerKey{rep, opts}
+	re.muReplace.Lock()
+	data, ok := re.replacers[key]
+	re.muReplace.Unlock()
+	if ok {
+		return data, nil
+	}
+
+	data, err := syntax.NewReplacerDataOptions(rep, re.caps, re.capsize, re.capnames, syntax.RegexOptions(re.options), syntax.ReplaceOptions(key.options))
+	if err != nil {
+		return nil, err
+	}
+
+	re.muReplace.Lock()
+	if re.replacers == nil {
+		re.replacers = make(map[replacerKey]*syntax.ReplacerData)
+	} else if len(re.replacers) >= maxCachedReplacers {
+		// make room by dropping any entry; a pattern in a hot loop is soon back
+		for k := range re.replacers {
+			delete(re.replacers, k)
+			break
+		}
+	}
+	re.replacers[key] = data
+	re.muReplace.Unlock()
+
+	return data, nil
+}
+
 // MatchEvaluator is a function that takes a match and returns a replacement string to be used
 type MatchEvaluator func(Match) string
 
+// MatchEvaluatorErr is a function that takes a match and returns a replacement string to be used,
+// or an error that stops the replacement
+type MatchEvaluatorErr func(*Match) (string, error)
+
 // Three very similar algorithms appear below: replace (pattern),
 // replace (evaluator), and split.
 
@@ -26,9 +110,7 @@ type MatchEvaluator func(Match) string
 //
 // Note that the special case of no matches is handled on its own:
 // with no matches, the input string is returned unchanged.
-// The right-to-left case is split out because StringBuilder
-// doesn't handle right-to-left string building directly very well.
-func replace(regex *Regexp, data *syntax.ReplacerData, evaluator MatchEvaluator, input string, startAt, count int) (string, error) {
+func replace(regex *Regexp, data *syntax.ReplacerData, evaluator MatchEvaluatorErr, input string, startAt, count int) (string, error) {
 	if count < -1 {
 		return "", errors.New("Count too small")
 	}
@@ -45,20 +127,64 @@ func replace(regex *Regexp, data *syntax.ReplacerData, evaluator MatchEvaluator,
 		return input, nil
 	}
 
-	buf := &bytes.Buffer{}
+	rw := &runeWriter{}
+	if err := replaceMatches(rw, regex, data, evaluator, m, count); err != nil {
+		return "", err
+	}
+	return string(rw.buf), nil
+}
+
+// replaceTo is replace writing its result to w
+func replaceTo(w io.Writer, regex *Regexp, data *syntax.ReplacerData, evaluator MatchEvaluatorErr, input string, startAt, count int) error {
+	if count < -1 {
+		return errors.New("Count too small")
+	}
+	if count == 0 {
+		return nil
+	}
+
+	m, err := regex.FindStringMatchStartingAt(input, startAt)
+
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		_, err = io.WriteString(w, input)
+		return err
+	}
+
+	rw := &runeWriter{w: w, buf: make([]byte, 0, runeWriterSize)}
+	if err := replaceMatches(rw, regex, data, evaluator, m, count); err != nil {
+		return err
+	}
+	return rw.flush()
+}
+
+// replaceMatches writes the text of m with m and up to count-1 of the matches
+// that follow it replaced.  The right-to-left case finds every match before
+// writing anything, since the output is written from the left.
+func replaceMatches(rw *runeWriter, regex *Regexp, data *syntax.ReplacerData, evaluator MatchEvaluatorErr, m *Match, count int) error {
 	text := m.text
+	var err error
 
 	if !regex.RightToLeft() {
 		prevat := 0
 		for m != nil {
 			if m.Index != prevat {
-				buf.WriteString(string(text[prevat:m.Index]))
+				rw.writeRunes(text[prevat:m.Index])
 			}
 			prevat = m.Index + m.Length
 			if evaluator == nil {
-				replacementImpl(data, buf, m)
+				replacementImpl(data, rw, m)
 			} else {
-				buf.WriteString(evaluator(*m))
+				rep, err := evaluator(m)
+				if err != nil {
+					return err
+				}
+				rw.writeString(rep)
+			}
+			if rw.err != nil {
+				return rw.err
 			}
 
 			count--
@@ -67,111 +193,185 @@ func replace(regex *Regexp, data *syntax.ReplacerData, evaluator MatchEvaluator,
 			}
 			m, err = regex.FindNextMatch(m)
 			if err != nil {
-				return "", nil
+				return err
 			}
 		}
 
 		if prevat < len(text) {
-			buf.WriteString(string(text[prevat:]))
+			rw.writeRunes(text[prevat:])
 		}
-	} else {
-		prevat := len(text)
-		var al []string
-
-		for m != nil {
-			if m.Index+m.Length != prevat {
-				al = append(al, string(text[m.Index+m.Length:prevat]))
-			}
-			prevat = m.Index
-			if evaluator == nil {
-				replacementImplRTL(data, &al, m)
-			} else {
-				al = append(al, evaluator(*m))
-			}
+		return rw.err
+	}
 
-			count--
-			if count == 0 {
-				break
-			}
-			m, err = regex.FindNextMatch(m)
+	var (
+		matches []*Match
+		reps    []string
+	)
+	for m != nil {
+		matches = append(matches, m)
+		if evaluator != nil {
+			rep, err := evaluator(m)
 			if err != nil {
-				return "", nil
+				return err
 			}
+			reps = append(reps, rep)
 		}
 
-		if prevat > 0 {
-			buf.WriteString(string(text[:prevat]))
+		count--
+		if count == 0 {
+			break
+		}
+		m, err = regex.FindNextMatch(m)
+		if err != nil {
+			return err
 		}
+	}
 
-		for i := len(al) - 1; i >= 0; i-- {
-			buf.WriteString(al[i])
+	prevat := 0
+	for i := len(matches) - 1; i >= 0; i-- {
+		m := matches[i]
+		if m.Index != prevat {
+			rw.writeRunes(text[prevat:m.Index])
+		}
+		prevat = m.Index + m.Length
+		if evaluator == nil {
+			replacementImpl(data, rw, m)
+		} else {
+			rw.writeString(reps[i])
+		}
+		if rw.err != nil {
+			return rw.err
 		}
 	}
 
-	return buf.String(), nil
+	if prevat < len(text) {
+		rw.writeRunes(text[prevat:])
+	}
+	return rw.err
 }
 
-// Given a Match, emits into the StringBuilder the evaluated
+// Given a Match, emits into the runeWriter the evaluated
 // substitution pattern.
-func replacementImpl(data *syntax.ReplacerData, buf *bytes.Buffer, m *Match) {
-	for _, r := range data.Rules {
+func replacementImpl(data *syntax.ReplacerData, rw *runeWriter, m *Match) {
+	replacementRules(data, data.Rules, rw, m)
+
+	// case conversion doesn't carry over to the next match
+	rw.caseAll, rw.caseNext = nil, nil
+}
+
+// replacementRules emits the substitution for a run of rules, which is either all
+// of the pattern or a branch of a conditional
+func replacementRules(data *syntax.ReplacerData, rules []int, rw *runeWriter, m *Match) {
+	for i := 0; i < len(rules); i++ {
+		r := rules[i]
 
 		if r >= 0 { // string lookup
-			buf.WriteString(data.Strings[r])
+			rw.writeString(data.Strings[r])
 		} else if r < -replaceSpecials { // group lookup
-			m.groupValueAppendToBuf(-replaceSpecials-1-r, buf)
+			m.groupValueAppendToBuf(-replaceSpecials-1-r, rw)
 		} else {
 			switch -replaceSpecials - 1 - r { // special insertion patterns
 			case replaceLeftPortion:
-				for i := 0; i < m.Index; i++ {
-					buf.WriteRune(m.text[i])
-				}
+				m.textAppendToBuf(0, m.Index, rw)
 			case replaceRightPortion:
-				for i := m.Index + m.Length; i < len(m.text); i++ {
-					buf.WriteRune(m.text[i])
-				}
+				m.textAppendToBuf(m.Index+m.Length, m.textLen(), rw)
 			case replaceLastGroup:
-				m.groupValueAppendToBuf(m.GroupCount()-1, buf)
+				m.groupValueAppendToBuf(m.GroupCount()-1, rw)
 			case replaceWholeString:
-				for i := 0; i < len(m.text); i++ {
-					buf.WriteRune(m.text[i])
+				m.textAppendToBuf(0, m.textLen(), rw)
+			case replaceUpperCase:
+				rw.caseAll = unicode.ToUpper
+			case replaceLowerCase:
+				rw.caseAll = unicode.ToLower
+			case replaceTitleNext:
+				rw.caseNext = unicode.ToTitle
+			case replaceLowerNext:
+				rw.caseNext = unicode.ToLower
+			case replaceEndCase:
+				rw.caseAll, rw.caseNext = nil, nil
+			case replaceLastMatchedGroup:
+				for g := m.GroupCount() - 1; g > 0; g-- {
+					if m.matchcount[g] > 0 {
+						m.groupValueAppendToBuf(g, rw)
+						break
+					}
+				}
+			case replaceConditional:
+				// the group is followed by the lengths of the yes and no branches
+				slot, yes, no := rules[i+1], rules[i+2], rules[i+3]
+				i += 4
+				if m.matchcount[slot] > 0 {
+					replacementRules(data, rules[i:i+yes], rw, m)
+				} else {
+					replacementRules(data, rules[i+yes:i+yes+no], rw, m)
 				}
+				i += yes + no - 1
 			}
 		}
 	}
 }
 
-func replacementImplRTL(data *syntax.ReplacerData, al *[]string, m *Match) {
-	l := *al
-	buf := &bytes.Buffer{}
+// runeWriterSize is how much output a runeWriter with a Writer buffers
+const runeWriterSize = 4096
 
-	for _, r := range data.Rules {
-		buf.Reset()
-		if r >= 0 { // string lookup
-			l = append(l, data.Strings[r])
-		} else if r < -replaceSpecials { // group lookup
-			m.groupValueAppendToBuf(-replaceSpecials-1-r, buf)
-			l = append(l, buf.String())
-		} else {
-			switch -replaceSpecials - 1 - r { // special insertion patterns
-			case replaceLeftPortion:
-				for i := 0; i < m.Index; i++ {
-					buf.WriteRune(m.text[i])
-				}
-			case replaceRightPortion:
-				for i := m.Index + m.Length; i < len(m.text); i++ {
-					buf.WriteRune(m.text[i])
-				}
-			case replaceLastGroup:
-				m.groupValueAppendToBuf(m.GroupCount()-1, buf)
-			case replaceWholeString:
-				for i := 0; i < len(m.text); i++ {
-					buf.WriteRune(m.text[i])
-				}
-			}
-			l = append(l, buf.String())
+// runeWriter encodes runes as UTF-8 into buf.  With a Writer it writes buf out
+// whenever it fills up, otherwise it keeps all the output.  The first error from
+// the Writer is kept in err and later output is dropped.
+type runeWriter struct {
+	w   io.Writer
+	buf []byte
+	err error
+
+	// case conversion from a replacement pattern
+	caseAll  func(rune) rune // applied to every rune
+	caseNext func(rune) rune // applied to the next rune only, instead of caseAll
+}
+
+func (rw *runeWriter) writeRune(r rune) {
+	if rw.caseNext != nil {
+		r = rw.caseNext(r)
+		rw.caseNext = nil
+	} else if rw.caseAll != nil {
+		r = rw.caseAll(r)
+	}
+
+	if r < utf8.RuneSelf {
+		rw.buf = append(rw.buf, byte(r))
+	} else {
+		var b [utf8.UTFMax]byte
+		n := utf8.EncodeRune(b[:], r)
+		rw.buf = append(rw.buf, b[:n]...)
+	}
+	if rw.w != nil && len(rw.buf) >= runeWriterSize {
+		rw.flush()
+	}
+}
+
+func (rw *runeWriter) writeRunes(rs []rune) {
+	for _, r := range rs {
+		rw.writeRune(r)
+	}
+}
+
+func (rw *runeWriter) writeString(s string) {
+	if rw.caseAll != nil || rw.caseNext != nil {
+		for _, r := range s {
+			rw.writeRune(r)
 		}
+		return
+	}
+
+	rw.buf = append(rw.buf, s...)
+	if rw.w != nil && len(rw.buf) >= runeWriterSize {
+		rw.flush()
 	}
+}
 
-	*al = l
+// flush writes the buffered output to the Writer
+func (rw *runeWriter) flush() error {
+	if rw.err == nil && len(rw.buf) > 0 {
+		_, rw.err = rw.w.Write(rw.buf)
+	}
+	rw.buf = rw.buf[:0]
+	return rw.err
 }
@@ -1,8 +1,13 @@
 package regexp2
 
 import (
+	"errors"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/dlclark/regexp2/syntax"
 )
 
 func TestReplace_Basic(t *testing.T) {
@@ -170,3 +175,426 @@ func TestReplace_NestedGroups(t *testing.T) {
 		t.Fatalf("Wrong result: %s", got)
 	}
 }
+
+func TestReplacer_SameAsReplace(t *testing.T) {
+	re := MustCompile(`(?<word>\w+)(\d)?`, 0)
+	for _, rep := range []string{"<$1>", "${word}$2", "$&$&", "[$`]", "x"} {
+		r, err := re.CompileReplacement(rep, 0)
+		if err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+		for _, args := range [][2]int{{-1, -1}, {3, -1}, {0, 1}} {
+			want, err := re.Replace("ab1 cd, ef", rep, args[0], args[1])
+			if err != nil {
+				t.Fatalf("Unexpected err: %v", err)
+			}
+			got, err := r.Replace("ab1 cd, ef", args[0], args[1])
+			if err != nil {
+				t.Fatalf("Unexpected err: %v", err)
+			}
+			if want != got {
+				t.Fatalf("Replacer %q failed, wanted %v, got %v", rep, want, got)
+			}
+		}
+	}
+}
+
+func TestReplacer_Error(t *testing.T) {
+	re := MustCompile(`a`, 0)
+	if _, err := re.CompileReplacement("${", 0); err != nil {
+		t.Fatalf("Unexpected err: %v", err)
+	}
+	re = MustCompile(`a`, ECMAScript)
+	if r, err := re.CompileReplacement("$1", 0); err != nil || r.String() != "$1" {
+		t.Fatalf("Unexpected result: %v %v", r, err)
+	}
+	if _, err := re.CompileReplacement("$1", ReplacePerl|ReplacePython); err != syntax.ErrReplaceDialects {
+		t.Fatalf("Wanted '%v'\nGot '%v'", syntax.ErrReplaceDialects, err)
+	}
+}
+
+func TestReplace_CacheBounded(t *testing.T) {
+	re := MustCompile(`a`, 0)
+	for i := 0; i < 3*maxCachedReplacers; i++ {
+		rep := strconv.Itoa(i % (maxCachedReplacers + 4))
+		if _, err := re.Replace("banana", rep, -1, -1); err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+		if len(re.replacers) > maxCachedReplacers {
+			t.Fatalf("cache grew to %v entries", len(re.replacers))
+		}
+	}
+
+	data, _ := re.replacerData("x", 0)
+	if again, _ := re.replacerData("x", 0); again != data {
+		t.Fatal("replacement pattern wasn't cached")
+	}
+
+	re.ReleaseMemory()
+	if len(re.replacers) != 0 {
+		t.Fatalf("ReleaseMemory left %v cached patterns", len(re.replacers))
+	}
+}
+
+func TestReplace_TimeoutAfterFirstMatch(t *testing.T) {
+	re := MustCompile(`c|(a+)+b`, 0)
+	re.MatchTimeout = time.Millisecond * 10
+	input := "c" + strings.Repeat("a", 40)
+
+	if str, err := re.Replace(input, "x", -1, -1); err == nil {
+		t.Fatalf("Expected timeout error, got %q", str)
+	}
+	if str, err := re.ReplaceFunc(input, func(m Match) string { return "x" }, -1, -1); err == nil {
+		t.Fatalf("Expected timeout error, got %q", str)
+	}
+
+	rtl := MustCompile(`c|b(a+)+`, RightToLeft)
+	rtl.MatchTimeout = time.Millisecond * 10
+	if str, err := rtl.Replace(strings.Repeat("a", 40)+"c", "x", -1, -1); err == nil {
+		t.Fatalf("Expected timeout error, got %q", str)
+	}
+}
+
+func TestReplaceFuncErr(t *testing.T) {
+	re := MustCompile(`\d+`, 0)
+	str, err := re.ReplaceFuncErr("a1 b22 c333", func(m *Match) (string, error) {
+		return strconv.Itoa(m.Length), nil
+	}, -1, -1)
+	if err != nil {
+		t.Fatalf("Unexpected err: %v", err)
+	}
+	if want, got := "a1 b2 c3", str; want != got {
+		t.Fatalf("Replace failed, wanted %v, got %v", want, got)
+	}
+
+	errStop := errors.New("stop")
+	calls := 0
+	str, err = re.ReplaceFuncErr("a1 b22 c333", func(m *Match) (string, error) {
+		calls++
+		if m.String() == "22" {
+			return "", errStop
+		}
+		return "x", nil
+	}, -1, -1)
+	if err != errStop || str != "" {
+		t.Fatalf("Wanted the evaluator's error\nGot %q %v", str, err)
+	}
+	if calls != 2 {
+		t.Fatalf("Evaluator called %v times after the error", calls)
+	}
+
+	rtl := MustCompile(`\d+`, RightToLeft)
+	if _, err := rtl.ReplaceFuncErr("a1 b22", func(m *Match) (string, error) {
+		return "", errStop
+	}, -1, -1); err != errStop {
+		t.Fatalf("Wanted the evaluator's error\nGot %v", err)
+	}
+}
+
+func TestReplaceTo_SameAsReplace(t *testing.T) {
+	long := strings.Repeat("héllo wörld ", runeWriterSize/4)
+	for _, opt := range []RegexOptions{0, RightToLeft} {
+		re := MustCompile(`(?<w>\w)(\w*)`, opt)
+		for _, input := range []string{"", "  ", "ab cd", "日本 語", long} {
+			for _, rep := range []string{"<$1>", "${w}$$$2", "[$`]", "$_"} {
+				if len(input) > 100 && rep == "$_" {
+					continue
+				}
+				for _, args := range [][2]int{{-1, -1}, {-1, 1}, {-1, 0}} {
+					want, err := re.Replace(input, rep, args[0], args[1])
+					if err != nil {
+						t.Fatalf("Unexpected err: %v", err)
+					}
+					var sb strings.Builder
+					if err := re.ReplaceTo(&sb, input, rep, args[0], args[1]); err != nil {
+						t.Fatalf("Unexpected err: %v", err)
+					}
+					if want != sb.String() {
+						t.Fatalf("ReplaceTo %q on %q (%v) failed, wanted %v, got %v", rep, input, opt, want, sb.String())
+					}
+				}
+			}
+
+			eval := func(m *Match) (string, error) { return strings.ToUpper(m.String()), nil }
+			want, err := re.ReplaceFuncErr(input, eval, -1, -1)
+			if err != nil {
+				t.Fatalf("Unexpected err: %v", err)
+			}
+			var sb strings.Builder
+			if err := re.ReplaceFuncTo(&sb, input, eval, -1, -1); err != nil {
+				t.Fatalf("Unexpected err: %v", err)
+			}
+			if want != sb.String() {
+				t.Fatalf("ReplaceFuncTo on %q (%v) failed, wanted %v, got %v", input, opt, want, sb.String())
+			}
+		}
+	}
+}
+
+// failWriter accepts n bytes and then fails
+type failWriter struct {
+	n      int
+	writes int
+}
+
+var errWriteFailed = errors.New("write failed")
+
+func (w *failWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if len(p) > w.n {
+		n := w.n
+		w.n = 0
+		return n, errWriteFailed
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestReplaceTo_WriteError(t *testing.T) {
+	re := MustCompile(`a`, 0)
+	input := strings.Repeat("ab", runeWriterSize)
+
+	calls := 0
+	w := &failWriter{n: 10}
+	err := re.ReplaceFuncTo(w, input, func(m *Match) (string, error) {
+		calls++
+		return "x", nil
+	}, -1, -1)
+	if err != errWriteFailed {
+		t.Fatalf("Wanted '%v'\nGot '%v'", errWriteFailed, err)
+	}
+	if w.writes != 1 || calls >= runeWriterSize {
+		t.Fatalf("replacement went on after the write failed: %v writes, %v matches", w.writes, calls)
+	}
+
+	if err := re.ReplaceTo(&failWriter{}, "bbb", "x", -1, -1); err != errWriteFailed {
+		t.Fatalf("Wanted '%v'\nGot '%v'", errWriteFailed, err)
+	}
+}
+
+func TestReplacer_ReplaceTo(t *testing.T) {
+	re := MustCompile(`\d`, 0)
+	r, err := re.CompileReplacement("<$0>", 0)
+	if err != nil {
+		t.Fatalf("Unexpected err: %v", err)
+	}
+	var sb strings.Builder
+	if err := r.ReplaceTo(&sb, "a1b2", -1, -1); err != nil {
+		t.Fatalf("Unexpected err: %v", err)
+	}
+	if want, got := "a<1>b<2>", sb.String(); want != got {
+		t.Fatalf("Replace failed, wanted %v, got %v", want, got)
+	}
+}
+
+func TestReplace_CaseConversion(t *testing.T) {
+	re := MustCompile(`(\w+) (?<last>\w+)`, 0)
+
+	for _, tt := range []struct {
+		input, rep, want string
+	}{
+		{"hello world", `\U$1\E $2`, "HELLO world"},
+		{"HELLO WORLD", `\L$1`, "hello"},
+		{"hello world", `\u$1 \u$2`, "Hello World"},
+		{"HELLO WORLD", `\u\L$1 \L\u$2`, "Hello World"},
+		{"Hello World", `\l${last}`, "world"},
+		{"hello world", `\Ux-$1-${last}\Ey`, "X-HELLO-WORLDy"},
+		{"hello world", `\Ufoo\LBAR`, "FOObar"},
+		{"hello world", `a\\Ub\q\`, `a\Ub\q\`},
+		{"straße ǆemal", `\U$1\E \u$2`, "STRAßE ǅemal"},
+		{"straße ǆemal", `\U$1 \u$2`, "STRAßE ǅEMAL"},
+		{"émile zola", `\u$1 \U$2`, "Émile ZOLA"},
+		{"ab cd, ef gh", `\u$1`, "Ab, Ef"},
+	} {
+		got, err := re.ReplaceWithOptions(tt.input, tt.rep, -1, -1, ReplaceCaseConversion)
+		if err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("Replace %q in %q failed, wanted %v, got %v", tt.rep, tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestReplace_CaseConversionOptIn(t *testing.T) {
+	re := MustCompile(`\w+`, 0)
+	if want, got := `\Uhello\E`, mustReplace(t, re, "hello", `\U$0\E`); want != got {
+		t.Fatalf("Replace failed, wanted %v, got %v", want, got)
+	}
+
+	// the cache keeps the parses with and without the option apart
+	if want, got := "HELLO", mustReplaceWith(t, re, "hello", `\U$0\E`, ReplaceCaseConversion); want != got {
+		t.Fatalf("Replace failed, wanted %v, got %v", want, got)
+	}
+	if want, got := `\Uhello\E`, mustReplace(t, re, "hello", `\U$0\E`); want != got {
+		t.Fatalf("Replace failed, wanted %v, got %v", want, got)
+	}
+}
+
+func mustReplace(t *testing.T, re *Regexp, input, rep string) string {
+	t.Helper()
+	return mustReplaceWith(t, re, input, rep, 0)
+}
+
+func mustReplaceWith(t *testing.T, re *Regexp, input, rep string, opts ReplaceOptions) string {
+	t.Helper()
+	s, err := re.ReplaceWithOptions(input, rep, -1, -1, opts)
+	if err != nil {
+		t.Fatalf("Unexpected err: %v", err)
+	}
+	return s
+}
+
+func TestReplace_Dialects(t *testing.T) {
+	for _, tt := range []struct {
+		opt                RegexOptions
+		dialect            ReplaceOptions
+		pattern, input     string
+		rep, want, wantErr string
+	}{
+		// results of String.prototype.replace
+		{0, ReplaceJavaScript, `(a)(b)`, "ab", "$2$1", "ba", ""},
+		{0, ReplaceJavaScript, `(a)(b)`, "ab", "$10", "a0", ""},
+		{0, ReplaceJavaScript, `(a)(b)`, "ab", "$3", "$3", ""},
+		{0, ReplaceJavaScript, `(a)(b)`, "ab", "$0", "$0", ""},
+		{0, ReplaceJavaScript, `(a)(b)`, "ab", "$01$02", "ab", ""},
+		{0, ReplaceJavaScript, `(a)(b)`, "ab", "$00", "$00", ""},
+		{0, ReplaceJavaScript, `(a)(b)`, "xaby", "[$`|$&|$']", "x[x|ab|y]y", ""},
+		{0, ReplaceJavaScript, `(a)(b)`, "ab", "$$1 $", "$1 $", ""},
+		{0, ReplaceJavaScript, `(a)(b)`, "ab", "$<x>", "$<x>", ""},
+		{0, ReplaceJavaScript, `(?<x>a)(?<y>b)`, "ab", "$<y>$<x>$<z>|", "ba|", ""},
+		{0, ReplaceJavaScript, `(?<x>a)`, "a", "$<x", "$<x", ""},
+		{0, ReplaceJavaScript, `(?<x>a)`, "a", "$<1>", "", ""},
+		{0, ReplaceJavaScript, `(a)|(b)`, "b", "[$1]", "[]", ""},
+		{0, ReplaceJavaScript, `(a)(b)(c)(d)(e)(f)(g)(h)(i)(j)(k)`, "abcdefghijk", "$11-$10-$1-$12", "k-j-a-a2", ""},
+		{0, ReplaceJavaScript, `(a)`, "a", "$_$+${1}", "$_$+${1}", ""},
+		{ECMAScript, 0, `(a)(b)`, "ab", "$<x>${1}$2", "$<x>${1}b", ""},
+
+		// results of Perl's s///
+		{0, ReplacePerl, `(a)(b)`, "ab", "$2$1", "ba", ""},
+		{0, ReplacePerl, `(a)(b)`, "ab", "$10", "", ""},
+		{0, ReplacePerl, `(a)(b)`, "ab", "${1}0", "a0", ""},
+		{0, ReplacePerl, `(a)(b)`, "ab", `\2\1`, "ba", ""},
+		{0, ReplacePerl, `(a)(b)`, "ab", `\10\18\81\08\1011`, "\x08\x018" + "81\x008A1", ""},
+		{0, ReplacePerl, `(a)(b)`, "ab", `\3\8|$3`, "|", ""},
+		{0, ReplacePerl, `(a)(b)`, "xaby", "[$`|$&|$']", "x[x|ab|y]y", ""},
+		{0, ReplacePerl, `(a)|(b)`, "b", "$+", "b", ""},
+		{0, ReplacePerl, `(a)|(b)`, "a", "$+", "a", ""},
+		{0, ReplacePerl, `(?<x>a)(?<y>b)`, "ab", "$+{y}$+{x}$+{z}|", "ba|", ""},
+		{0, ReplacePerl, `(?<x>a)(b)`, "ab", "$+{x}$+{1}.", "a.", ""},
+		{0, ReplacePerl, `(a)(b)`, "ab", `\$1 \\ \t\q\e`, "$1 \\ \tq\x1b", ""},
+		{0, ReplacePerl, `(a)(b)`, "ab", `${3}x${1}${x}`, "xa${x}", ""},
+		{0, ReplacePerl, `(\w+) (\w+)`, "hello world", `\u$1 \U$2\E!`, "Hello WORLD!", ""},
+		{0, ReplacePerl, `(a)`, "a", `\L\uAB\E`, "Ab", ""},
+
+		// results of Python's re.sub
+		{0, ReplacePython, `(a)(b)`, "ab", `\2\1`, "ba", ""},
+		{0, ReplacePython, `(a)(b)`, "ab", `\g<2>\g<1>\g<0>`, "baab", ""},
+		{0, ReplacePython, `(?<x>a)(?<y>b)`, "ab", `\g<y>\g<x>`, "ba", ""},
+		{0, ReplacePython, `(a)(b)`, "ab", `\0\101\01x`, "\x00A\x01x", ""},
+		{0, ReplacePython, `(a)(b)`, "ab", `\n\t\\\&$1é\é`, "\n\t\\\\&$1é\\é", ""},
+		{0, ReplacePython, `(a)|(b)`, "b", `[\1]`, "[]", ""},
+		{0, ReplacePython, `(a)(b)(c)(d)(e)(f)(g)(h)(i)(j)(k)`, "abcdefghijk", `\11-\10-\1`, "k-j-a", ""},
+		{0, ReplacePython, `(a)(b)`, "ab", `\10`, "", "reference to undefined group number 10"},
+		{0, ReplacePython, `(a)`, "a", `\18`, "", "reference to undefined group number 18"},
+		{0, ReplacePython, `(a)(b)`, "ab", `\g<3>`, "", "reference to undefined group number 3"},
+		{0, ReplacePython, `(a)(b)`, "ab", `\g<x>`, "", "reference to undefined group name x"},
+		{0, ReplacePython, `(a)`, "a", `\g<1`, "", "malformed \\g<...> group reference"},
+		{0, ReplacePython, `(a)`, "a", `\g1`, "", "malformed \\g<...> group reference"},
+		{0, ReplacePython, `(a)`, "a", `\q`, "", "unrecognized escape sequence \\q"},
+		{0, ReplacePython, `(a)`, "a", `\`, "", "illegal \\ at end of pattern"},
+		{0, ReplacePython, `(a)`, "a", `\400`, "", "octal escape value \\400 outside of range 0-0o377"},
+		{0, ReplacePython | ReplaceCaseConversion, `(a)`, "a", `\U\1`, "A", ""},
+
+		// .NET, also when chosen explicitly for ECMAScript
+		{0, ReplaceDotNet, `(a)(b)`, "ab", "$10${2}$+$_", "$10bbab", ""},
+		{ECMAScript, ReplaceDotNet, `(a)(b)`, "ab", "$10${2}", "a0b", ""},
+	} {
+		re := MustCompile(tt.pattern, tt.opt)
+
+		got, err := re.ReplaceWithOptions(tt.input, tt.rep, -1, -1, tt.dialect)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%v %q: wanted error %q, got %q %v", tt.dialect, tt.rep, tt.wantErr, got, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v %q: unexpected err: %v", tt.dialect, tt.rep, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%v %q on %q failed, wanted %q, got %q", tt.dialect, tt.rep, tt.input, tt.want, got)
+		}
+
+		r, err := re.CompileReplacement(tt.rep, tt.dialect)
+		if err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+		if got, _ := r.Replace(tt.input, -1, -1); got != tt.want {
+			t.Errorf("%v %q on %q with Replacer failed, wanted %q, got %q", tt.dialect, tt.rep, tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestReplace_Conditionals(t *testing.T) {
+	re := MustCompile(`(a)?(?<b>b*)c`, 0)
+
+	for _, tt := range []struct {
+		input, rep, want string
+	}{
+		{"ac", `${1:+yes:no}`, "yes"},
+		{"c", `${1:+yes:no}`, "no"},
+		{"c", `${1:+yes}`, ""},
+		// an empty capture still participated
+		{"ac", `${b:+[$2]:none}`, "[]"},
+		{"abbc", `(?{b}<${b}>:none)`, "<bb>"},
+		{"abbc", `(?1$1-:)(?2$2)`, "a-bb"},
+		{"bc", `(?1$1-:no-)$0`, "no-bc"},
+		{"ac", `${1:+${2:+both:one}:none}`, "both"},
+		{"ac", `${1:+a\:b\}c}`, "a:b}c"},
+		{"ac", `${1:+(x)}`, "(x)"},
+		{"ac", `(x) (?x) ${x} $$`, "(x) (?x) ${x} $"},
+		{"ac", `${1}:+`, "a:+"},
+		// the group number ends at the first non-digit
+		{"ac", `(?1 yes:no)`, " yes"},
+		{"ac", `${1:+0:x}`, "0"},
+	} {
+		got, err := re.ReplaceWithOptions(tt.input, tt.rep, -1, -1, ReplaceConditionals)
+		if err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("Replace %q in %q failed, wanted %v, got %v", tt.rep, tt.input, tt.want, got)
+		}
+	}
+
+	for _, rep := range []string{`${3:+x}`, `${c:+x}`, `(?3x)`, `(?{c}x)`, `${1:+x`, `(?1x`, `${1:+x:y`} {
+		if _, err := re.ReplaceWithOptions("ac", rep, -1, -1, ReplaceConditionals); err == nil {
+			t.Errorf("Replace %q: expected error", rep)
+		}
+	}
+
+	// without the option they're literal text
+	re = MustCompile(`(a)`, 0)
+	if want, got := "(?1x)", mustReplace(t, re, "a", `(?1x)`); want != got {
+		t.Fatalf("Replace failed, wanted %v, got %v", want, got)
+	}
+}
+
+func TestReplace_ConditionalsDialects(t *testing.T) {
+	for _, tt := range []struct {
+		dialect          ReplaceOptions
+		rep, input, want string
+	}{
+		{ReplacePython, `\1(?2\2:-)`, "ab", "ab"},
+		{ReplacePython, `\g<1>${2:+\2:-}$`, "a", "a-$"},
+		{ReplacePerl, `\U${1:+$1}\E(?2$2:-)`, "a", "A-"},
+		{ReplaceJavaScript, `$1${x:+$<x>:-}`, "ab", "ab"},
+	} {
+		re := MustCompile(`(a)(?<x>b)?`, 0)
+		if got := mustReplaceWith(t, re, tt.input, tt.rep, tt.dialect|ReplaceConditionals); got != tt.want {
+			t.Errorf("Replace %q in %q failed, wanted %v, got %v", tt.rep, tt.input, tt.want, got)
+		}
+	}
+}
@@ -18,10 +18,34 @@ type runner struct {
 	code *syntax.Code
 
 	runtextstart int // starting point for search
+	runtextstop  int // last point a match may start at (inclusive)
+	runmatchend  int // where a match must end, -1 if anywhere
 
-	runtext    []rune // text to search
+	// when runallmax is set, up to that many distinct matches are collected
+	// in runall by backtracking after each success rather than stopping
+	runall    []*Match
+	runallmax int
+
+	// the longest match found so far at the current position in Longest mode
+	runbest    *Match
+	runbestpos int
+
+	runtext    []rune // text to search, nil when it's an Input that isn't contiguous
+	runinput   Input  // text to search when runtext is nil
 	runtextpos int    // current position in text
-	runtextend int
+	runtextbeg int    // first position the current code may read
+	runtextend int    // end of the text the current code may read
+
+	// the region matches lie in, and the text that anchors, boundaries and
+	// lookarounds see; they differ when the region has transparent bounds
+	runregionbeg, runregionend   int
+	runvisiblebeg, runvisibleend int
+	runswitchbounds              bool // the bounds change inside lookarounds
+	runregion                    *region
+
+	// the run of an Input's text that charAt last read from
+	runchunk      []rune
+	runchunkstart int
 
 	// The backtracking stack.  Opcodes use this to store data regarding
 	// what they have matched and where to backtrack to.  Each "frame" on
@@ -56,6 +80,25 @@ type runner struct {
 
 	runtrackcount int // count of states that may do backtracking
 
+	// Set when the outcome of the scan could change if the text continued
+	// past runtextend, along with the first start position whose attempt
+	// ran into the end.
+	runhitend   bool
+	runhitstart int
+
+	// Set by callers that can supply more text and need runhitend to be exact.
+	// Otherwise it's recorded conservatively where being exact costs time.
+	runtrackhitend bool
+
+	// With runsuspend set an attempt that needs text past runtextend stops at
+	// the instruction that needed it, setting runsuspended, so that it can be
+	// resumed once there's more text.  runloopdone is how many chars a suspended
+	// greedy loop had already matched and runattempt is where the attempt started.
+	runsuspend   bool
+	runsuspended bool
+	runloopdone  int
+	runattempt   int
+
 	runmatch *Match // result object
 
 	ignoreTimeout       bool
@@ -75,6 +118,12 @@ type runner struct {
 // textstart is -1 to start at the "beginning" (depending on Right-To-Left), otherwise an index in input
 // input is the string to search for our regex pattern
 func (re *Regexp) run(quick bool, textstart int, input []rune) (*Match, error) {
+	return re.runRange(quick, textstart, -1, input)
+}
+
+// runRange is like run, but no match may start past textstop (before it for Right-To-Left).
+// textstop is -1 to allow matches to start anywhere in input.
+func (re *Regexp) runRange(quick bool, textstart, textstop int, input []rune) (*Match, error) {
 
 	// get a cached runner
 	runner := re.getRunner()
@@ -88,7 +137,7 @@ func (re *Regexp) run(quick bool, textstart int, input []rune) (*Match, error) {
 		}
 	}
 
-	return runner.scan(input, textstart, quick, re.MatchTimeout)
+	return runner.scan(input, textstart, textstop, quick, re.MatchTimeout)
 }
 
 // Scans the string to find the first match. Uses the Match object
@@ -101,33 +150,136 @@ func (re *Regexp) run(quick bool, textstart int, input []rune) (*Match, error) {
 // The optimizer can compute a set of candidate starting characters,
 // and we could use a separate method Skip() that will quickly scan past
 // any characters that we know can't match.
-func (r *runner) scan(rt []rune, textstart int, quick bool, timeout time.Duration) (*Match, error) {
+func (r *runner) scan(rt []rune, textstart, textstop int, quick bool, timeout time.Duration) (*Match, error) {
+	r.setInput(rt, nil)
+	return r.scanText(textstart, textstop, quick, timeout)
+}
+
+// setInput sets the text to search, either a rune slice or an Input
+func (r *runner) setInput(rt []rune, in Input) {
+	r.runtext = rt
+
+	// this runs for every match, so the fields only an Input, a region or
+	// FindAllMatchesAt use are only written when they're set
+	end := len(rt)
+	if in != nil || r.runinput != nil {
+		r.runinput = in
+		r.runchunk, r.runchunkstart = nil, 0
+		if in != nil {
+			end = in.Len()
+		}
+	}
+	r.runregionbeg, r.runregionend = 0, end
+	r.runtextbeg, r.runtextend = 0, end
+	r.runvisiblebeg, r.runvisibleend = 0, end
+	r.runswitchbounds = false
+	if r.runregion != nil {
+		r.runregion = nil
+	}
+	r.runmatchend = -1
+	if r.runall != nil {
+		r.runall = nil
+	}
+	r.runallmax = 0
+}
+
+// setRegion restricts matches to [beg, end) of the text.  With transparent
+// bounds anchors, boundaries and lookarounds still see all of the text.
+func (r *runner) setRegion(beg, end int, transparent bool) {
+	r.runregionbeg, r.runregionend = beg, end
+	r.runtextbeg, r.runtextend = beg, end
+	r.runvisiblebeg, r.runvisibleend = beg, end
+	if transparent {
+		r.runvisiblebeg = 0
+		r.runvisibleend = len(r.runtext)
+		if r.runinput != nil {
+			r.runvisibleend = r.runinput.Len()
+		}
+	}
+	r.runswitchbounds = r.code.InLookaround != nil &&
+		(r.runvisiblebeg != beg || r.runvisibleend != end)
+}
+
+// switchBounds sets the bounds for the current code position when it enters or
+// leaves a lookaround.  Backtracking can't go back into a lookaround it left, so
+// only Setjump, Backjump and Forejump switch.
+func (r *runner) switchBounds() {
+	if r.runswitchbounds {
+		r.setBounds(r.code.InLookaround[r.codepos])
+	}
+}
+
+// setBounds sets the text the current code may read
+func (r *runner) setBounds(inLookaround bool) {
+	if inLookaround {
+		r.runtextbeg, r.runtextend = r.runvisiblebeg, r.runvisibleend
+	} else {
+		r.runtextbeg, r.runtextend = r.runregionbeg, r.runregionend
+	}
+}
+
+// scanText is scan for the text given to setInput
+func (r *runner) scanText(textstart, textstop int, quick bool, timeout time.Duration) (*Match, error) {
 	r.timeout = timeout
 	r.ignoreTimeout = (time.Duration(math.MaxInt64) == timeout)
 	r.runtextstart = textstart
-	r.runtext = rt
-	r.runtextend = len(rt)
 
 	stoppos := r.runtextend
+	if r.re.RightToLeft() {
+		stoppos = r.runtextbeg
+	}
+	if textstop >= 0 {
+		stoppos = textstop
+	}
+	r.runtextstop = stoppos
+	r.runhitend = false
+	r.runhitstart = -1
+	r.runsuspended = false
+
+	r.runtextpos = textstart
+
+	r.startTimeoutWatch()
+	return r.scanFrom(quick, false)
+}
+
+// resume continues a scan that was suspended at the end of the text, now that
+// rt holds more text after it
+func (r *runner) resume(rt []rune, timeout time.Duration) (*Match, error) {
+	r.timeout = timeout
+	r.ignoreTimeout = (time.Duration(math.MaxInt64) == timeout)
+
+	r.runtext = rt
+	r.setRegion(0, len(rt), false)
+	r.runtextstop = r.runtextend
+	r.runmatch.text = rt
+	r.runhitend = false
+	r.runhitstart = -1
+	r.runsuspended = false
+
+	r.startTimeoutWatch()
+	return r.scanFrom(false, true)
+}
+
+// scanFrom is the search loop of scanText.  With resume set it first continues
+// the suspended attempt rather than starting one at runtextpos.
+func (r *runner) scanFrom(quick, resume bool) (*Match, error) {
+	stoppos := r.runtextstop
 	bump := 1
 
 	if r.re.RightToLeft() {
 		bump = -1
-		stoppos = 0
 	}
 
-	r.runtextpos = textstart
-	initted := false
+	initted := resume
 
-	r.startTimeoutWatch()
 	for {
-		if r.re.Debug() {
+		if r.re.Debug() && !resume {
 			//fmt.Printf("\nSearch content: %v\n", string(r.runtext))
-			fmt.Printf("\nSearch range: from 0 to %v\n", r.runtextend)
+			fmt.Printf("\nSearch range: from %v to %v\n", r.runtextbeg, r.runtextend)
 			fmt.Printf("Firstchar search starting at %v stopping at %v\n", r.runtextpos, stoppos)
 		}
 
-		if r.findFirstChar() {
+		if resume || r.findFirstChar() {
 			if err := r.checkTimeout(); err != nil {
 				return nil, err
 			}
@@ -137,13 +289,26 @@ func (r *runner) scan(rt []rune, textstart int, quick bool, timeout time.Duratio
 				initted = true
 			}
 
-			if r.re.Debug() {
-				fmt.Printf("Executing engine starting at %v\n\n", r.runtextpos)
+			if !resume {
+				if r.re.Debug() {
+					fmt.Printf("Executing engine starting at %v\n\n", r.runtextpos)
+				}
+				r.runattempt = r.runtextpos
 			}
 
-			if err := r.execute(); err != nil {
+			if err := r.execute(resume); err != nil {
 				return nil, err
 			}
+			resume = false
+			if r.runsuspended {
+				return nil, nil
+			}
+			if r.runswitchbounds {
+				r.setBounds(false)
+			}
+			if r.runhitend && r.runhitstart < 0 {
+				r.runhitstart = r.runattempt
+			}
 
 			if r.runmatch.matchcount[0] > 0 {
 				// We'll return a match even if it touches a previous empty match
@@ -158,7 +323,7 @@ func (r *runner) scan(rt []rune, textstart int, quick bool, timeout time.Duratio
 
 		// failure!
 
-		if r.runtextpos == stoppos {
+		if r.runtextpos == stoppos || r.pastStop(bump) {
 			r.tidyMatch(true)
 			return nil, nil
 		}
@@ -172,9 +337,23 @@ func (r *runner) scan(rt []rune, textstart int, quick bool, timeout time.Duratio
 	// We never get here
 }
 
-func (r *runner) execute() error {
+// pastStop returns true if the current position is beyond the last
+// point a match may start at
+func (r *runner) pastStop(bump int) bool {
+	if bump > 0 {
+		return r.runtextpos > r.runtextstop
+	}
+	return r.runtextpos < r.runtextstop
+}
 
-	r.goTo(0)
+// execute runs the engine for an attempt at runtextpos.  With resume set it
+// continues a suspended attempt at the instruction that suspended it.
+func (r *runner) execute(resume bool) error {
+
+	if !resume {
+		r.goTo(0)
+		r.runbest = nil
+	}
 
 	for {
 
@@ -188,6 +367,26 @@ func (r *runner) execute() error {
 
 		switch r.operator {
 		case syntax.Stop:
+			if r.runmatch.matchcount[0] > 0 {
+				if r.runmatchend >= 0 && r.runtextpos != r.runmatchend {
+					// the match has to end somewhere else
+					break
+				}
+				if r.runallmax > 0 {
+					if r.collectMatch() {
+						// look for another way to match
+						break
+					}
+				} else if r.re.longest {
+					// look for a longer way to match
+					r.keepLongest()
+					break
+				}
+			} else if r.runbest != nil {
+				// no other way to match, the longest one wins
+				r.runmatch, r.runbest = r.runbest, nil
+				r.runtextpos = r.runbestpos
+			}
 			return nil
 
 		case syntax.Nothing:
@@ -469,10 +668,12 @@ func (r *runner) execute() error {
 			r.stackPush2(r.trackpos(), r.crawlpos())
 			r.trackPush()
 			r.advance(0)
+			r.switchBounds()
 			continue
 
 		case syntax.Setjump | syntax.Back:
 			r.stackPopN(2)
+			r.switchBounds()
 			break
 
 		case syntax.Backjump:
@@ -485,6 +686,7 @@ func (r *runner) execute() error {
 			for r.crawlpos() != r.stackPeekN(1) {
 				r.uncapture()
 			}
+			r.switchBounds()
 
 			break
 
@@ -495,6 +697,7 @@ func (r *runner) execute() error {
 			r.stackPopN(2)
 			r.trackto(r.stackPeek())
 			r.trackPush1(r.stackPeekN(1))
+			r.switchBounds()
 			r.advance(0)
 			continue
 
@@ -520,32 +723,35 @@ func (r *runner) execute() error {
 			if r.rightchars() > 0 && r.charAt(r.textPos()) != '\n' {
 				break
 			}
+			if r.rightchars() == 0 && r.hitEnd() {
+				return nil
+			}
 			r.advance(0)
 			continue
 
 		case syntax.Boundary:
-			if !r.isBoundary(r.textPos(), 0, r.runtextend) {
+			if !r.isBoundary(r.textPos(), r.runvisiblebeg, r.runvisibleend) || r.runsuspended {
 				break
 			}
 			r.advance(0)
 			continue
 
 		case syntax.Nonboundary:
-			if r.isBoundary(r.textPos(), 0, r.runtextend) {
+			if r.isBoundary(r.textPos(), r.runvisiblebeg, r.runvisibleend) || r.runsuspended {
 				break
 			}
 			r.advance(0)
 			continue
 
 		case syntax.ECMABoundary:
-			if !r.isECMABoundary(r.textPos(), 0, r.runtextend) {
+			if !r.isECMABoundary(r.textPos(), r.runvisiblebeg, r.runvisibleend) || r.runsuspended {
 				break
 			}
 			r.advance(0)
 			continue
 
 		case syntax.NonECMABoundary:
-			if r.isECMABoundary(r.textPos(), 0, r.runtextend) {
+			if r.isECMABoundary(r.textPos(), r.runvisiblebeg, r.runvisibleend) || r.runsuspended {
 				break
 			}
 			r.advance(0)
@@ -582,6 +788,10 @@ func (r *runner) execute() error {
 				break
 			}
 
+			// more text would make this fail
+			if r.hitEnd() {
+				return nil
+			}
 			r.advance(0)
 			continue
 
@@ -589,11 +799,14 @@ func (r *runner) execute() error {
 			if r.rightchars() > 0 {
 				break
 			}
+			if r.hitEnd() {
+				return nil
+			}
 			r.advance(0)
 			continue
 
 		case syntax.One:
-			if r.forwardchars() < 1 || r.forwardcharnext() != rune(r.operand(0)) {
+			if !r.hasForwardchars(1) || r.forwardcharnext() != rune(r.operand(0)) {
 				break
 			}
 
@@ -601,7 +814,7 @@ func (r *runner) execute() error {
 			continue
 
 		case syntax.Notone:
-			if r.forwardchars() < 1 || r.forwardcharnext() == rune(r.operand(0)) {
+			if !r.hasForwardchars(1) || r.forwardcharnext() == rune(r.operand(0)) {
 				break
 			}
 
@@ -610,7 +823,7 @@ func (r *runner) execute() error {
 
 		case syntax.Set:
 
-			if r.forwardchars() < 1 || !r.code.Sets[r.operand(0)].CharIn(r.forwardcharnext()) {
+			if !r.hasForwardchars(1) || !r.code.Sets[r.operand(0)].CharIn(r.forwardcharnext()) {
 				break
 			}
 
@@ -645,13 +858,16 @@ func (r *runner) execute() error {
 		case syntax.Onerep:
 
 			c := r.operand(1)
+			ch := rune(r.operand(0))
 
 			if r.forwardchars() < c {
+				// the repeat fails either way, but did the text run out before a mismatch?
+				if r.repHitEnd() && r.restMatches(func(tch rune) bool { return tch == ch }) {
+					r.hitEnd()
+				}
 				break
 			}
 
-			ch := rune(r.operand(0))
-
 			for c > 0 {
 				if r.forwardcharnext() != ch {
 					goto BreakBackward
@@ -665,11 +881,14 @@ func (r *runner) execute() error {
 		case syntax.Notonerep:
 
 			c := r.operand(1)
+			ch := rune(r.operand(0))
 
 			if r.forwardchars() < c {
+				if r.repHitEnd() && r.restMatches(func(tch rune) bool { return tch != ch }) {
+					r.hitEnd()
+				}
 				break
 			}
-			ch := rune(r.operand(0))
 
 			for c > 0 {
 				if r.forwardcharnext() == ch {
@@ -684,13 +903,15 @@ func (r *runner) execute() error {
 		case syntax.Setrep:
 
 			c := r.operand(1)
+			set := r.code.Sets[r.operand(0)]
 
 			if r.forwardchars() < c {
+				if r.repHitEnd() && r.restMatches(set.CharIn) {
+					r.hitEnd()
+				}
 				break
 			}
 
-			set := r.code.Sets[r.operand(0)]
-
 			for c > 0 {
 				if !set.CharIn(r.forwardcharnext()) {
 					goto BreakBackward
@@ -711,6 +932,9 @@ func (r *runner) execute() error {
 
 			ch := rune(r.operand(0))
 			i := c
+			if r.runloopdone > 0 {
+				i -= r.resumeLoop()
+			}
 
 			for ; i > 0; i-- {
 				if r.forwardcharnext() != ch {
@@ -718,6 +942,9 @@ func (r *runner) execute() error {
 					break
 				}
 			}
+			if i == 0 && r.loopHitEnd(c) {
+				return nil
+			}
 
 			if c > i {
 				r.trackPush2(c-i-1, r.textPos()-r.bump())
@@ -736,6 +963,9 @@ func (r *runner) execute() error {
 
 			ch := rune(r.operand(0))
 			i := c
+			if r.runloopdone > 0 {
+				i -= r.resumeLoop()
+			}
 
 			for ; i > 0; i-- {
 				if r.forwardcharnext() == ch {
@@ -743,6 +973,9 @@ func (r *runner) execute() error {
 					break
 				}
 			}
+			if i == 0 && r.loopHitEnd(c) {
+				return nil
+			}
 
 			if c > i {
 				r.trackPush2(c-i-1, r.textPos()-r.bump())
@@ -761,6 +994,9 @@ func (r *runner) execute() error {
 
 			set := r.code.Sets[r.operand(0)]
 			i := c
+			if r.runloopdone > 0 {
+				i -= r.resumeLoop()
+			}
 
 			for ; i > 0; i-- {
 				if !set.CharIn(r.forwardcharnext()) {
@@ -768,6 +1004,9 @@ func (r *runner) execute() error {
 					break
 				}
 			}
+			if i == 0 && r.loopHitEnd(c) {
+				return nil
+			}
 
 			if c > i {
 				r.trackPush2(c-i-1, r.textPos()-r.bump())
@@ -808,13 +1047,9 @@ func (r *runner) execute() error {
 
 		case syntax.Onelazy, syntax.Notonelazy:
 
-			c := r.operand(1)
-
-			if c > r.forwardchars() {
-				c = r.forwardchars()
-			}
-
-			if c > 0 {
+			// the count isn't limited by the text left, so that it stays right
+			// for an attempt that's resumed with more text
+			if c := r.operand(1); c > 0 {
 				r.trackPush2(c-1, r.textPos())
 			}
 
@@ -823,13 +1058,7 @@ func (r *runner) execute() error {
 
 		case syntax.Setlazy:
 
-			c := r.operand(1)
-
-			if c > r.forwardchars() {
-				c = r.forwardchars()
-			}
-
-			if c > 0 {
+			if c := r.operand(1); c > 0 {
 				r.trackPush2(c-1, r.textPos())
 			}
 
@@ -842,7 +1071,10 @@ func (r *runner) execute() error {
 			pos := r.trackPeekN(1)
 			r.textto(pos)
 
-			if r.forwardcharnext() != rune(r.operand(0)) {
+			if r.runtextpos == r.runtextend && r.lazyHitEnd() {
+				return nil
+			}
+			if r.forwardchars() == 0 || r.forwardcharnext() != rune(r.operand(0)) {
 				break
 			}
 
@@ -861,7 +1093,10 @@ func (r *runner) execute() error {
 			pos := r.trackPeekN(1)
 			r.textto(pos)
 
-			if r.forwardcharnext() == rune(r.operand(0)) {
+			if r.runtextpos == r.runtextend && r.lazyHitEnd() {
+				return nil
+			}
+			if r.forwardchars() == 0 || r.forwardcharnext() == rune(r.operand(0)) {
 				break
 			}
 
@@ -880,7 +1115,10 @@ func (r *runner) execute() error {
 			pos := r.trackPeekN(1)
 			r.textto(pos)
 
-			if !r.code.Sets[r.operand(0)].CharIn(r.forwardcharnext()) {
+			if r.runtextpos == r.runtextend && r.lazyHitEnd() {
+				return nil
+			}
+			if r.forwardchars() == 0 || !r.code.Sets[r.operand(0)].CharIn(r.forwardcharnext()) {
 				break
 			}
 
@@ -900,6 +1138,11 @@ func (r *runner) execute() error {
 	BreakBackward:
 		;
 
+		if r.runsuspended {
+			// the instruction needs more text, it runs again on resume
+			return nil
+		}
+
 		// "break Backward" comes here:
 		r.backtrack()
 	}
@@ -1123,12 +1366,14 @@ func (r *runner) operand(i int) int {
 	return r.code.Codes[r.codepos+i+1]
 }
 
+// leftchars returns the number of chars anchors can see to the left
 func (r *runner) leftchars() int {
-	return r.runtextpos
+	return r.runtextpos - r.runvisiblebeg
 }
 
+// rightchars returns the number of chars anchors can see to the right
 func (r *runner) rightchars() int {
-	return r.runtextend - r.runtextpos
+	return r.runvisibleend - r.runtextpos
 }
 
 func (r *runner) bump() int {
@@ -1140,18 +1385,113 @@ func (r *runner) bump() int {
 
 func (r *runner) forwardchars() int {
 	if r.rightToLeft {
-		return r.runtextpos
+		return r.runtextpos - r.runtextbeg
 	}
 	return r.runtextend - r.runtextpos
 }
 
+// hasForwardchars returns true if at least c chars are left in the current
+// direction.  Running out of text left-to-right is noted as hitting the end.
+func (r *runner) hasForwardchars(c int) bool {
+	if r.forwardchars() >= c {
+		return true
+	}
+	if !r.rightToLeft {
+		r.hitEnd()
+	}
+	return false
+}
+
+// repHitEnd is called when fewer chars are left than a repeat or string needs.
+// It returns true if the rest of the text has to be compared to know whether it
+// failed only because the text ran out.  Unless hitEnd is tracked exactly that's
+// assumed instead, since comparing makes failed attempts cost the length of the text.
+func (r *runner) repHitEnd() bool {
+	if r.rightToLeft {
+		return false
+	}
+	if !r.runtrackhitend && !r.runsuspend {
+		r.hitEnd()
+		return false
+	}
+	return true
+}
+
+// restMatches returns true if in accepts every char left in the text
+func (r *runner) restMatches(in func(rune) bool) bool {
+	for i := r.runtextpos; i < r.runtextend; i++ {
+		ch := r.charAt(i)
+		if r.caseInsensitive {
+			ch = unicode.ToLower(ch)
+		}
+		if !in(ch) {
+			return false
+		}
+	}
+	return true
+}
+
+// loopHitEnd is called when a greedy loop consumed c chars without a mismatch.
+// If the loop would have taken more chars then it stopped at the end of the text.
+// It returns true if the attempt is suspended, to resume after the c chars.
+func (r *runner) loopHitEnd(c int) bool {
+	if !r.rightToLeft && c < r.operand(1) && r.runtextpos == r.runtextend && r.hitEnd() {
+		r.runloopdone = c
+		r.runtextpos -= c
+		return true
+	}
+	return false
+}
+
+// resumeLoop skips the chars a greedy loop matched before it was suspended
+// and returns how many there were
+func (r *runner) resumeLoop() int {
+	c := r.runloopdone
+	r.runloopdone = 0
+	r.runtextpos += c
+	return c
+}
+
+// lazyHitEnd is called when backtracking into a lazy loop that's at the end of
+// the text, so it can't take another char.  It returns true if the attempt is
+// suspended until there's more text.
+func (r *runner) lazyHitEnd() bool {
+	if r.rightToLeft || !r.hitEnd() {
+		return false
+	}
+	// the instruction pops its frame again on resume
+	r.runtrackpos -= 2
+	return true
+}
+
+// hitEnd records that the current attempt depended on there being no text
+// past runtextend.  It returns true if the attempt is suspended instead, which
+// the caller has to do before changing any state for the current instruction.
+func (r *runner) hitEnd() bool {
+	r.runhitend = true
+	if r.runsuspend {
+		r.runsuspended = true
+		return true
+	}
+	return false
+}
+
+// hitEndAt records that an attempt starting at start would run into the
+// end of the text
+func (r *runner) hitEndAt(start int) {
+	r.runhitend = true
+	if r.runhitstart < 0 {
+		r.runhitstart = start
+	}
+}
+
 func (r *runner) forwardcharnext() rune {
 	var ch rune
 	if r.rightToLeft {
 		r.runtextpos--
-		ch = r.runtext[r.runtextpos]
+		ch = r.charAt(r.runtextpos)
 	} else {
-		ch = r.runtext[r.runtextpos]
+		ch = r.charAt(r.runtextpos)
 		r.runtextpos++
 	}
 
@@ -1167,23 +1507,36 @@ func (r *runner) runematch(str []rune) bool {
 	c := len(str)
 	if !r.rightToLeft {
 		if r.runtextend-r.runtextpos < c {
+			if r.repHitEnd() && r.runesMatchAt(str[:r.runtextend-r.runtextpos], r.runtextpos) {
+				// the text ran out while the string still matched
+				r.hitEnd()
+			}
 			return false
 		}
 
 		pos = r.runtextpos + c
 	} else {
-		if r.runtextpos-0 < c {
+		if r.runtextpos-r.runtextbeg < c {
 			return false
 		}
 
 		pos = r.runtextpos
 	}
 
-	if !r.caseInsensitive {
+	if rt := r.runtext; rt != nil && !r.caseInsensitive {
+		// the common case, a rune slice compared directly
 		for c != 0 {
 			c--
 			pos--
-			if str[c] != r.runtext[pos] {
+			if str[c] != rt[pos] {
+				return false
+			}
+		}
+	} else if !r.caseInsensitive {
+		for c != 0 {
+			c--
+			pos--
+			if str[c] != r.charAt(pos) {
 				return false
 			}
 		}
@@ -1191,7 +1544,7 @@ func (r *runner) runematch(str []rune) bool {
 		for c != 0 {
 			c--
 			pos--
-			if str[c] != unicode.ToLower(r.runtext[pos]) {
+			if str[c] != unicode.ToLower(r.charAt(pos)) {
 				return false
 			}
 		}
@@ -1211,12 +1564,16 @@ func (r *runner) refmatch(index, len int) bool {
 
 	if !r.rightToLeft {
 		if r.runtextend-r.runtextpos < len {
+			if r.repHitEnd() && r.runesMatchAt(r.textRunes(index, index+r.runtextend-r.runtextpos), r.runtextpos) {
+				// the text ran out while the reference still matched
+				r.hitEnd()
+			}
 			return false
 		}
 
 		pos = r.runtextpos + len
 	} else {
-		if r.runtextpos-0 < len {
+		if r.runtextpos-r.runtextbeg < len {
 			return false
 		}
 
@@ -1231,7 +1588,7 @@ func (r *runner) refmatch(index, len int) bool {
 			c--
 			cmpos--
 			pos--
-			if r.runtext[cmpos] != r.runtext[pos] {
+			if r.charAt(cmpos) != r.charAt(pos) {
 				return false
 			}
 
@@ -1242,7 +1599,7 @@ func (r *runner) refmatch(index, len int) bool {
 			cmpos--
 			pos--
 
-			if unicode.ToLower(r.runtext[cmpos]) != unicode.ToLower(r.runtext[pos]) {
+			if unicode.ToLower(r.charAt(cmpos)) != unicode.ToLower(r.charAt(pos)) {
 				return false
 			}
 		}
@@ -1257,6 +1614,20 @@ func (r *runner) refmatch(index, len int) bool {
 	return true
 }
 
+// runesMatchAt compares str to the text starting at pos, honoring case-insensitivity
+func (r *runner) runesMatchAt(str []rune, pos int) bool {
+	for i, ch := range str {
+		tch := r.charAt(pos + i)
+		if r.caseInsensitive {
+			ch, tch = unicode.ToLower(ch), unicode.ToLower(tch)
+		}
+		if ch != tch {
+			return false
+		}
+	}
+	return true
+}
+
 func (r *runner) backwardnext() {
 	if r.rightToLeft {
 		r.runtextpos++
@@ -1266,48 +1637,108 @@ func (r *runner) backwardnext() {
 }
 
 func (r *runner) charAt(j int) rune {
-	return r.runtext[j]
+	if r.runtext != nil {
+		return r.runtext[j]
+	}
+	return r.inputCharAt(j)
+}
+
+// inputCharAt reads a char from an Input, using the current chunk if it holds j
+func (r *runner) inputCharAt(j int) rune {
+	if i := j - r.runchunkstart; i >= 0 && i < len(r.runchunk) {
+		return r.runchunk[i]
+	}
+	if c, ok := r.runinput.(ChunkedInput); ok {
+		r.runchunk, r.runchunkstart = c.Chunk(j)
+		return r.runchunk[j-r.runchunkstart]
+	}
+	return r.runinput.RuneAt(j)
+}
+
+// textRunes returns the text from start up to end
+func (r *runner) textRunes(start, end int) []rune {
+	return inputRunes(r.runtext, r.runinput, start, end)
 }
 
 func (r *runner) findFirstChar() bool {
 
 	if 0 != (r.code.Anchors & (syntax.AnchorBeginning | syntax.AnchorStart | syntax.AnchorEndZ | syntax.AnchorEnd)) {
 		if !r.code.RightToLeft {
-			if (0 != (r.code.Anchors&syntax.AnchorBeginning) && r.runtextpos > 0) ||
+			if (0 != (r.code.Anchors&syntax.AnchorBeginning) && r.runtextpos > r.runvisiblebeg) ||
 				(0 != (r.code.Anchors&syntax.AnchorStart) && r.runtextpos > r.runtextstart) {
 				r.runtextpos = r.runtextend
 				return false
 			}
-			if 0 != (r.code.Anchors&syntax.AnchorEndZ) && r.runtextpos < r.runtextend-1 {
-				r.runtextpos = r.runtextend - 1
-			} else if 0 != (r.code.Anchors&syntax.AnchorEnd) && r.runtextpos < r.runtextend {
+			if 0 != (r.code.Anchors&syntax.AnchorEndZ) && r.runtextpos < r.runvisibleend-1 {
+				r.runtextpos = r.runvisibleend - 1
+			} else if 0 != (r.code.Anchors&syntax.AnchorEnd) && r.runtextpos < r.runvisibleend {
+				r.runtextpos = r.runvisibleend
+			}
+			if r.runtextpos > r.runtextend || r.pastStop(1) {
+				// the end anchor is past where a match may start
 				r.runtextpos = r.runtextend
+				return false
 			}
 		} else {
-			if (0 != (r.code.Anchors&syntax.AnchorEnd) && r.runtextpos < r.runtextend) ||
-				(0 != (r.code.Anchors&syntax.AnchorEndZ) && (r.runtextpos < r.runtextend-1 ||
-					(r.runtextpos == r.runtextend-1 && r.charAt(r.runtextpos) != '\n'))) ||
+			if (0 != (r.code.Anchors&syntax.AnchorEnd) && r.runtextpos < r.runvisibleend) ||
+				(0 != (r.code.Anchors&syntax.AnchorEndZ) && (r.runtextpos < r.runvisibleend-1 ||
+					(r.runtextpos == r.runvisibleend-1 && r.charAt(r.runtextpos) != '\n'))) ||
 				(0 != (r.code.Anchors&syntax.AnchorStart) && r.runtextpos < r.runtextstart) {
-				r.runtextpos = 0
+				r.runtextpos = r.runtextbeg
 				return false
 			}
-			if 0 != (r.code.Anchors&syntax.AnchorBeginning) && r.runtextpos > 0 {
-				r.runtextpos = 0
+			if 0 != (r.code.Anchors&syntax.AnchorBeginning) && r.runtextpos > r.runvisiblebeg {
+				r.runtextpos = r.runvisiblebeg
+			}
+			if r.runtextpos < r.runtextbeg || r.pastStop(-1) {
+				// the beginning anchor is past where a match may start
+				r.runtextpos = r.runtextbeg
+				return false
 			}
 		}
 
 		if r.code.BmPrefix != nil {
-			return r.code.BmPrefix.IsMatch(r.runtext, r.runtextpos, 0, r.runtextend)
+			if r.runtext == nil {
+				return r.isBmPrefixMatch()
+			}
+			if r.code.BmPrefix.IsMatch(r.runtext, r.runtextpos, r.runtextbeg, r.runtextend) {
+				return true
+			}
+			if !r.code.RightToLeft && r.code.BmPrefix.IsPartialMatch(r.runtext, r.runtextpos, r.runtextend) {
+				r.hitEndAt(r.runtextpos)
+			}
+			return false
 		}
 
 		return true // found a valid start or end anchor
 	} else if r.code.BmPrefix != nil {
-		r.runtextpos = r.code.BmPrefix.Scan(r.runtext, r.runtextpos, 0, r.runtextend)
+		// don't look at text that can only belong to matches starting past the stop
+		beglimit, endlimit := r.runtextbeg, r.runtextend
+		if r.code.RightToLeft {
+			if l := r.runtextstop - r.code.BmPrefix.Len(); l > beglimit {
+				beglimit = l
+			}
+		} else if l := r.runtextstop + r.code.BmPrefix.Len(); l < endlimit {
+			endlimit = l
+		}
+		startpos := r.runtextpos
+		if r.runtext != nil {
+			r.runtextpos = r.code.BmPrefix.Scan(r.runtext, r.runtextpos, beglimit, endlimit)
+		} else {
+			r.runtextpos = r.scanBmPrefix(beglimit, endlimit)
+		}
 
 		if r.runtextpos == -1 {
 			if r.code.RightToLeft {
-				r.runtextpos = 0
+				r.runtextpos = r.runtextbeg
 			} else {
+				if endlimit == r.runtextend {
+					// the prefix could still start in the last few chars
+					if l := r.runtextend - r.code.BmPrefix.Len() + 1; l > startpos {
+						startpos = l
+					}
+					r.hitEndAt(startpos)
+				}
 				r.runtextpos = r.runtextend
 			}
 			return false
@@ -1321,17 +1752,29 @@ func (r *runner) findFirstChar() bool {
 	r.rightToLeft = r.code.RightToLeft
 	r.caseInsensitive = r.code.FcPrefix.CaseInsensitive
 
+	// only look at chars that could start a match before the stop
+	chars := r.forwardchars()
+	toEnd := true
+	if r.rightToLeft {
+		if c := r.runtextpos - r.runtextstop + 1; c < chars {
+			chars = c
+		}
+	} else if c := r.runtextstop - r.runtextpos + 1; c < chars {
+		chars = c
+		toEnd = false
+	}
+
 	set := r.code.FcPrefix.PrefixSet
 	if set.IsSingleton() {
 		ch := set.SingletonChar()
-		for i := r.forwardchars(); i > 0; i-- {
+		for i := chars; i > 0; i-- {
 			if ch == r.forwardcharnext() {
 				r.backwardnext()
 				return true
 			}
 		}
 	} else {
-		for i := r.forwardchars(); i > 0; i-- {
+		for i := chars; i > 0; i-- {
 			n := r.forwardcharnext()
 			//fmt.Printf("%v in %v: %v\n", string(n), set.String(), set.CharIn(n))
 			if set.CharIn(n) {
@@ -1341,9 +1784,110 @@ func (r *runner) findFirstChar() bool {
 		}
 	}
 
+	if !r.rightToLeft && toEnd {
+		// the first char of a match could still follow
+		r.hitEndAt(r.runtextend)
+	}
 	return false
 }
 
+// matchBmPrefix compares the BmPrefix to the text at index.  It's used instead of the
+// Boyer-Moore tables when the text is an Input the tables can't index directly.
+// partial is true when the text ends part way through a matching prefix.
+func (r *runner) matchBmPrefix(index int) (match, partial bool) {
+	if index < r.runtextbeg {
+		return false, false
+	}
+
+	pattern := r.code.BmPrefix.Pattern()
+	caseInsensitive := r.code.BmPrefix.CaseInsensitive()
+	n := len(pattern)
+	if r.runtextend-index < n {
+		n = r.runtextend - index
+		partial = true
+	}
+	for i := 0; i < n; i++ {
+		ch := r.charAt(index + i)
+		if caseInsensitive {
+			ch = unicode.ToLower(ch)
+		}
+		if ch != pattern[i] {
+			return false, false
+		}
+	}
+	return !partial, partial
+}
+
+// isBmPrefixMatch is BmPrefix.IsMatch and IsPartialMatch for an Input
+func (r *runner) isBmPrefixMatch() bool {
+	if r.code.RightToLeft {
+		match, _ := r.matchBmPrefix(r.runtextpos - r.code.BmPrefix.Len())
+		return match
+	}
+
+	match, partial := r.matchBmPrefix(r.runtextpos)
+	if partial {
+		r.hitEndAt(r.runtextpos)
+	}
+	return match
+}
+
+// scanBmPrefix is BmPrefix.Scan for an Input
+func (r *runner) scanBmPrefix(beglimit, endlimit int) int {
+	l := r.code.BmPrefix.Len()
+	if !r.code.RightToLeft {
+		for pos := r.runtextpos; pos+l <= endlimit; pos++ {
+			if match, _ := r.matchBmPrefix(pos); match {
+				return pos
+			}
+		}
+	} else {
+		for pos := r.runtextpos; pos-l >= beglimit; pos-- {
+			if match, _ := r.matchBmPrefix(pos - l); match {
+				return pos
+			}
+		}
+	}
+	return -1
+}
+
+// collectMatch adds a copy of the current match to runall unless it's already
+// there, and returns true if more matches should be collected
+func (r *runner) collectMatch() bool {
+	m := r.runmatch.clone()
+	m.tidy(r.runtextpos)
+	m.hitEnd = r.runhitend
+
+	for _, prev := range r.runall {
+		if prev.sameCaptures(m) {
+			return true
+		}
+	}
+	r.runall = append(r.runall, m)
+	return len(r.runall) < r.runallmax
+}
+
+// keepLongest records the current match if it's preferred to the best one
+// found so far in Longest mode
+func (r *runner) keepLongest() {
+	if r.runbest != nil {
+		if l := r.runmatch.matches[0][1]; l < r.runbest.Length {
+			return
+		} else if l == r.runbest.Length {
+			m := r.runmatch.clone()
+			m.tidy(r.runtextpos)
+			if m.groupsPreferredTo(r.runbest) {
+				r.runbest, r.runbestpos = m, r.runtextpos
+			}
+			return
+		}
+	}
+
+	r.runbest = r.runmatch.clone()
+	r.runbest.tidy(r.runtextpos)
+	r.runbestpos = r.runtextpos
+}
+
 func (r *runner) initMatch() {
 	// Use a hashtable'ed Match object if the capture numbers are sparse
 
@@ -1356,6 +1900,12 @@ func (r *runner) initMatch() {
 	} else {
 		r.runmatch.reset(r.runtext, r.runtextstart)
 	}
+	if r.runinput != nil || r.runmatch.input != nil {
+		r.runmatch.input = r.runinput
+	}
+	if r.runmatch.region != r.runregion {
+		r.runmatch.region = r.runregion
+	}
 
 	// note we test runcrawl, because it is the last one to be allocated
 	// If there is an alloc failure in the middle of the three allocations,
@@ -1398,6 +1948,7 @@ func (r *runner) tidyMatch(quick bool) *Match {
 		r.runmatch = nil
 
 		match.tidy(r.runtextpos)
+		match.hitEnd = r.runhitend
 		return match
 	} else {
 		// send back our match -- it's not leaving the package, so it's safe to not clean it up
@@ -1518,7 +2069,7 @@ func (r *runner) textposDescription() string {
 	}
 
 	if r.runtextpos > 0 {
-		buf.WriteString(syntax.CharDescription(r.runtext[r.runtextpos-1]))
+		buf.WriteString(syntax.CharDescription(r.charAt(r.runtextpos - 1)))
 	} else {
 		buf.WriteRune('^')
 	}
@@ -1526,7 +2077,7 @@ func (r *runner) textposDescription() string {
 	buf.WriteRune('>')
 
 	for i := r.runtextpos; i < r.runtextend; i++ {
-		buf.WriteString(syntax.CharDescription(r.runtext[i]))
+		buf.WriteString(syntax.CharDescription(r.charAt(i)))
 	}
 	if buf.Len() >= 64 {
 		buf.Truncate(61)
@@ -1542,13 +2093,19 @@ func (r *runner) textposDescription() string {
 // at the specified index is a boundary or not. It's just not worth
 // emitting inline code for this logic.
 func (r *runner) isBoundary(index, startpos, endpos int) bool {
-	return (index > startpos && syntax.IsWordChar(r.runtext[index-1])) !=
-		(index < endpos && syntax.IsWordChar(r.runtext[index]))
+	if index == endpos {
+		r.hitEnd()
+	}
+	return (index > startpos && syntax.IsWordChar(r.charAt(index-1))) !=
+		(index < endpos && syntax.IsWordChar(r.charAt(index)))
 }
 
 func (r *runner) isECMABoundary(index, startpos, endpos int) bool {
-	return (index > startpos && syntax.IsECMAWordChar(r.runtext[index-1])) !=
-		(index < endpos && syntax.IsECMAWordChar(r.runtext[index]))
+	if index == endpos {
+		r.hitEnd()
+	}
+	return (index > startpos && syntax.IsECMAWordChar(r.charAt(index-1))) !=
+		(index < endpos && syntax.IsECMAWordChar(r.charAt(index)))
 }
 
 // this seems like a comment to justify randomly picking 1000 :-P
@@ -1597,13 +2154,21 @@ func (r *runner) doCheckTimeout() error {
 		//Debug.WriteLine("About to throw RegexMatchTimeoutException.")
 	}
 
-	return fmt.Errorf("match timeout after %v on input `%v`", r.timeout, string(r.runtext))
+	return fmt.Errorf("match timeout after %v on input `%v`", r.timeout, string(r.textRunes(0, r.runtextend)))
 }
 
 func (r *runner) initTrackCount() {
 	r.runtrackcount = r.code.TrackCount
 }
 
+// maxPooledStackSize is the largest backtracking, grouping or crawl stack (in ints)
+// that a runner keeps when it goes back to the pool.  Runners that grew past it
+// during a pathological match drop their stacks and reallocate on next use.
+const maxPooledStackSize = 1 << 14
+
+// maxPooledRunners is the most idle runners a Regexp keeps for reuse
+const maxPooledRunners = 16
+
 // getRunner returns a run to use for matching re.
 // It uses the re's runner cache if possible, to avoid
 // unnecessary allocation.
@@ -1624,11 +2189,50 @@ func (re *Regexp) getRunner() *runner {
 }
 
 // putRunner returns a runner to the re's cache.
-// There is no attempt to limit the size of the cache, so it will
-// grow to the maximum number of simultaneous matches
-// run using re.  (The cache empties when re gets garbage collected.)
+// The cache keeps at most maxPooledRunners idle runners, so a burst of
+// simultaneous matches doesn't pin their memory for the life of re.
 func (re *Regexp) putRunner(r *runner) {
+	r.trim()
 	re.muRun.Lock()
-	re.runner = append(re.runner, r)
+	if len(re.runner) < maxPooledRunners {
+		re.runner = append(re.runner, r)
+	}
 	re.muRun.Unlock()
 }
+
+// trim prepares an idle runner for the pool: it drops the
+// reference to the last input and any stacks that grew too large.
+// It runs after every match, so fields that are usually nil are
+// only written when they're set.
+func (r *runner) trim() {
+	r.runtext = nil
+	if r.runinput != nil {
+		r.runinput, r.runchunk = nil, nil
+	}
+	if r.runregion != nil {
+		r.runregion = nil
+	}
+	r.runtrackhitend, r.runsuspend = false, false
+	if r.runall != nil {
+		r.runall = nil
+	}
+	if r.runmatch != nil {
+		r.runmatch.text = nil
+		if r.runmatch.input != nil {
+			r.runmatch.input = nil
+		}
+	}
+
+	// nothing grew past the size every runner may keep
+	if len(r.runtrack) <= maxPooledStackSize && len(r.runstack) <= maxPooledStackSize &&
+		len(r.runcrawl) <= maxPooledStackSize {
+		return
+	}
+	limit := r.code.TrackCount * 8
+	if len(r.runtrack) > limit || len(r.runstack) > limit || len(r.runcrawl) > limit {
+		// initMatch allocates all three again when runcrawl is nil
+		r.runtrack, r.runtrackpos = nil, 0
+		r.runstack, r.runstackpos = nil, 0
+		r.runcrawl, r.runcrawlpos = nil, 0
+	}
+}
@@ -0,0 +1,862 @@
+/*
+Package stdcompat wraps the regexp2 engine in the API of Go's regexp package, so code
+written against *regexp.Regexp can switch engines by changing an import.
+
+The Regexp type has the method set of regexp.Regexp and follows its matching rules:
+positions are byte offsets into the input, subexpressions are numbered by the position
+of their opening parenthesis (named groups included), FindAll-style methods skip empty
+matches that abut the previous match, and replacement templates use the $1 and ${name}
+syntax of regexp.Expand.
+
+# Syntax
+
+Patterns are compiled by regexp2 with the regexp2.RE2 option, not by the regexp
+package, so the pattern language is regexp2's.  Most notably the character classes
+are Unicode-aware: \w, \d and \s match any Unicode letter, digit and space, and \b
+and \B find word boundaries using that \w, where regexp only considers ASCII.  On
+"héllo", \w+ matches the whole word rather than stopping at the é.  Spell out
+[0-9A-Za-z_] and the like where ASCII-only classes are needed.  Single-letter
+Unicode classes must be braced, as in \p{L}.
+
+# Timeouts
+
+The regexp package can't time out, so none of its methods return an error.  A Regexp
+created by Compile has no timeout unless regexp2.DefaultMatchTimeout is set; to give it
+one, compile the pattern with regexp2 and use Wrap.  A search that runs past the
+MatchTimeout panics with a *TimeoutError, which callers that set a timeout should
+recover:
+
+	defer func() {
+		if r := recover(); r != nil {
+			if terr, ok := r.(*stdcompat.TimeoutError); ok {
+				err = terr
+				return
+			}
+			panic(r)
+		}
+	}()
+*/
+package stdcompat
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dlclark/regexp2"
+)
+
+// TimeoutError is the panic value of a search that runs past the MatchTimeout of
+// the underlying regexp2.Regexp
+type TimeoutError struct {
+	Err error // the error returned by the regexp2 engine
+}
+
+func (e *TimeoutError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Regexp is the representation of a compiled regular expression.
+// A Regexp is safe for concurrent use by multiple goroutines, except for
+// configuration methods, such as Longest.
+type Regexp struct {
+	re   *regexp2.Regexp
+	expr string
+
+	groups []int    // regexp2 group number of each subexpression, in pattern order
+	names  []string // subexpression names, "" for unnamed ones
+
+	longest bool
+}
+
+// Compile parses a regular expression and returns, if successful,
+// a Regexp object that can be used to match against text.
+func Compile(expr string) (*Regexp, error) {
+	re, err := regexp2.Compile(expr, regexp2.RE2)
+	if err != nil {
+		return nil, err
+	}
+	return Wrap(re), nil
+}
+
+// CompilePOSIX is like Compile but the Regexp prefers the leftmost-longest match,
+// as set by Longest.  Unlike regexp.CompilePOSIX it doesn't restrict the syntax.
+func CompilePOSIX(expr string) (*Regexp, error) {
+	re, err := Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	re.Longest()
+	return re, nil
+}
+
+// MustCompile is like Compile but panics if the expression cannot be parsed.
+// It simplifies safe initialization of global variables holding compiled regular
+// expressions.
+func MustCompile(str string) *Regexp {
+	re, err := Compile(str)
+	if err != nil {
+		panic(`stdcompat: Compile(` + strconv.Quote(str) + `): ` + err.Error())
+	}
+	return re
+}
+
+// MustCompilePOSIX is like CompilePOSIX but panics if the expression cannot be parsed.
+func MustCompilePOSIX(str string) *Regexp {
+	re, err := CompilePOSIX(str)
+	if err != nil {
+		panic(`stdcompat: CompilePOSIX(` + strconv.Quote(str) + `): ` + err.Error())
+	}
+	return re
+}
+
+// Wrap returns a Regexp that runs re, for patterns that need regexp2 options or a
+// MatchTimeout.  re shouldn't be RightToLeft.  The returned Regexp shares re, so
+// prefer its Longest method to calling the one on re.
+func Wrap(re *regexp2.Regexp) *Regexp {
+	ret := &Regexp{
+		re:     re,
+		expr:   re.String(),
+		groups: re.GroupNumbersByPosition(),
+	}
+	ret.names = make([]string, len(ret.groups))
+	for i, num := range ret.groups[1:] {
+		if name := re.GroupNameFromNumber(num); name != strconv.Itoa(num) {
+			ret.names[i+1] = name
+		}
+	}
+	return ret
+}
+
+// MatchString reports whether the string s contains any match of the regular
+// expression pattern.
+func MatchString(pattern string, s string) (matched bool, err error) {
+	re, err := Compile(pattern)
+	if err != nil {
+		return false, err
+	}
+	return re.MatchString(s), nil
+}
+
+// Match reports whether the byte slice b contains any match of the regular
+// expression pattern.
+func Match(pattern string, b []byte) (matched bool, err error) {
+	re, err := Compile(pattern)
+	if err != nil {
+		return false, err
+	}
+	return re.Match(b), nil
+}
+
+// MatchReader reports whether the text returned by the RuneReader contains any
+// match of the regular expression pattern.
+func MatchReader(pattern string, r io.RuneReader) (matched bool, err error) {
+	re, err := Compile(pattern)
+	if err != nil {
+		return false, err
+	}
+	return re.MatchReader(r), nil
+}
+
+// QuoteMeta returns a string that escapes all regular expression metacharacters
+// inside the argument text; the returned string is a regular expression matching
+// the literal text.
+func QuoteMeta(s string) string {
+	return regexp.QuoteMeta(s)
+}
+
+// String returns the source text used to compile the regular expression.
+func (re *Regexp) String() string {
+	return re.expr
+}
+
+// Copy returns a new Regexp object copied from re.  Calling Longest on one copy
+// does not affect another.
+//
+// Deprecated: In earlier releases, when using a Regexp in multiple goroutines,
+// giving each goroutine its own copy helped to avoid lock contention.
+// As of Go 1.12, using Copy is no longer necessary to avoid lock contention.
+// Copy may still be appropriate if the reason for its use is to make
+// two copies with different Longest settings.
+func (re *Regexp) Copy() *Regexp {
+	inner := regexp2.MustCompile(re.expr, re.re.Options())
+	inner.MatchTimeout = re.re.MatchTimeout
+	if re.longest {
+		inner.Longest()
+	}
+	re2 := *re
+	re2.re = inner
+	return &re2
+}
+
+// Longest makes future searches prefer leftmost-longest matches.
+// This method modifies the Regexp and may not be called concurrently
+// with any other methods.
+func (re *Regexp) Longest() {
+	re.longest = true
+	re.re.Longest()
+}
+
+// NumSubexp returns the number of parenthesized subexpressions in this Regexp.
+func (re *Regexp) NumSubexp() int {
+	return len(re.groups) - 1
+}
+
+// SubexpNames returns the names of the parenthesized subexpressions
+// in this Regexp.  The name for the first sub-expression is names[1],
+// so that if m is a match slice, the name for m[i] is SubexpNames()[i].
+// Since the Regexp as a whole cannot be named, names[0] is always
+// the empty string.  The slice should not be modified.
+func (re *Regexp) SubexpNames() []string {
+	return re.names
+}
+
+// SubexpIndex returns the index of the first subexpression with the given name,
+// or -1 if there is no subexpression with that name.
+func (re *Regexp) SubexpIndex(name string) int {
+	if name != "" {
+		for i, s := range re.names {
+			if name == s {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// LiteralPrefix returns a literal string that must begin any match
+// of the regular expression re.  It returns the boolean true if the
+// literal string comprises the entire regular expression.  The prefix
+// comes from regexp2's compiled code and may be shorter than the one
+// the regexp package finds.
+func (re *Regexp) LiteralPrefix() (prefix string, complete bool) {
+	return re.re.LiteralPrefix()
+}
+
+// AppendText implements encoding.TextAppender.  The output
+// matches that of calling the String method.
+func (re *Regexp) AppendText(b []byte) ([]byte, error) {
+	return append(b, re.String()...), nil
+}
+
+// MarshalText implements encoding.TextMarshaler.  The output
+// matches that of calling the String method.
+func (re *Regexp) MarshalText() ([]byte, error) {
+	return []byte(re.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler by calling
+// Compile on the encoded value.
+func (re *Regexp) UnmarshalText(text []byte) error {
+	newRE, err := Compile(string(text))
+	if err != nil {
+		return err
+	}
+	*re = *newRE
+	return nil
+}
+
+// input is text being searched as runes, with the byte offset of each rune
+type input struct {
+	runes   []rune
+	offsets []int // offsets[i] is the byte offset of runes[i], the last is the length
+}
+
+func stringInput(s string) input {
+	in := input{
+		runes:   make([]rune, 0, len(s)),
+		offsets: make([]int, 0, len(s)+1),
+	}
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		in.runes = append(in.runes, r)
+		in.offsets = append(in.offsets, i)
+		i += size
+	}
+	in.offsets = append(in.offsets, len(s))
+	return in
+}
+
+func bytesInput(b []byte) input {
+	in := input{
+		runes:   make([]rune, 0, len(b)),
+		offsets: make([]int, 0, len(b)+1),
+	}
+	for i := 0; i < len(b); {
+		r, size := utf8.DecodeRune(b[i:])
+		in.runes = append(in.runes, r)
+		in.offsets = append(in.offsets, i)
+		i += size
+	}
+	in.offsets = append(in.offsets, len(b))
+	return in
+}
+
+func readerInput(rr io.RuneReader) input {
+	var in input
+	pos := 0
+	for {
+		r, size, err := rr.ReadRune()
+		if err != nil {
+			break
+		}
+		in.runes = append(in.runes, r)
+		in.offsets = append(in.offsets, pos)
+		pos += size
+	}
+	in.offsets = append(in.offsets, pos)
+	return in
+}
+
+// find returns the first match starting at or after rune index pos
+func (re *Regexp) find(in input, pos int) *regexp2.Match {
+	m, err := re.re.FindRunesMatchStartingAt(in.runes, pos)
+	if err != nil {
+		panic(&TimeoutError{Err: err})
+	}
+	return m
+}
+
+// submatchIndex returns the byte offset pairs of the subexpressions of m,
+// or just of the whole match when groups is false
+func (re *Regexp) submatchIndex(in input, m *regexp2.Match, groups bool) []int {
+	if !groups {
+		return []int{in.offsets[m.Index], in.offsets[m.Index+m.Length]}
+	}
+	a := make([]int, 2*len(re.groups))
+	for i, num := range re.groups {
+		g := m.GroupByNumber(num)
+		if g == nil || len(g.Captures) == 0 {
+			a[2*i], a[2*i+1] = -1, -1
+			continue
+		}
+		a[2*i], a[2*i+1] = in.offsets[g.Index], in.offsets[g.Index+g.Length]
+	}
+	return a
+}
+
+func (re *Regexp) firstIndex(in input, groups bool) []int {
+	m := re.find(in, 0)
+	if m == nil {
+		return nil
+	}
+	return re.submatchIndex(in, m, groups)
+}
+
+// allIndex calls deliver with the indexes of up to n successive matches,
+// all of them if n < 0.  Like the regexp package it skips an empty match
+// that abuts the previous match.
+func (re *Regexp) allIndex(in input, n int, groups bool, deliver func([]int)) {
+	end := len(in.runes)
+	for pos, i, prevMatchEnd := 0, 0, -1; (n < 0 || i < n) && pos <= end; {
+		m := re.find(in, pos)
+		if m == nil {
+			break
+		}
+
+		accept := true
+		matchEnd := m.Index + m.Length
+		if m.Length == 0 {
+			if m.Index == prevMatchEnd {
+				// an empty match right after a previous match isn't allowed
+				accept = false
+			}
+			pos = matchEnd + 1
+		} else {
+			pos = matchEnd
+		}
+		prevMatchEnd = matchEnd
+
+		if accept {
+			deliver(re.submatchIndex(in, m, groups))
+			i++
+		}
+	}
+}
+
+// Match reports whether the byte slice b contains any match of the regular expression re.
+func (re *Regexp) Match(b []byte) bool {
+	return re.firstIndex(bytesInput(b), false) != nil
+}
+
+// MatchString reports whether the string s contains any match of the regular expression re.
+func (re *Regexp) MatchString(s string) bool {
+	return re.firstIndex(stringInput(s), false) != nil
+}
+
+// MatchReader reports whether the text returned by the RuneReader
+// contains any match of the regular expression re.
+func (re *Regexp) MatchReader(r io.RuneReader) bool {
+	return re.firstIndex(readerInput(r), false) != nil
+}
+
+// Find returns a slice holding the text of the leftmost match in b of the regular expression.
+// A return value of nil indicates no match.
+func (re *Regexp) Find(b []byte) []byte {
+	a := re.firstIndex(bytesInput(b), false)
+	if a == nil {
+		return nil
+	}
+	return b[a[0]:a[1]:a[1]]
+}
+
+// FindIndex returns a two-element slice of integers defining the location of
+// the leftmost match in b of the regular expression.  The match itself is at
+// b[loc[0]:loc[1]].
+// A return value of nil indicates no match.
+func (re *Regexp) FindIndex(b []byte) (loc []int) {
+	return re.firstIndex(bytesInput(b), false)
+}
+
+// FindString returns a string holding the text of the leftmost match in s of the regular
+// expression.  If there is no match, the return value is an empty string,
+// but it will also be empty if the regular expression successfully matches
+// an empty string.  Use FindStringIndex or FindStringSubmatch if it is
+// necessary to distinguish these cases.
+func (re *Regexp) FindString(s string) string {
+	a := re.firstIndex(stringInput(s), false)
+	if a == nil {
+		return ""
+	}
+	return s[a[0]:a[1]]
+}
+
+// FindStringIndex returns a two-element slice of integers defining the
+// location of the leftmost match in s of the regular expression.  The match
+// itself is at s[loc[0]:loc[1]].
+// A return value of nil indicates no match.
+func (re *Regexp) FindStringIndex(s string) (loc []int) {
+	return re.firstIndex(stringInput(s), false)
+}
+
+// FindReaderIndex returns a two-element slice of integers defining the
+// location of the leftmost match of the regular expression in text read from
+// the RuneReader.  The match text was found in the input stream at
+// byte offset loc[0] through loc[1]-1.
+// A return value of nil indicates no match.
+func (re *Regexp) FindReaderIndex(r io.RuneReader) (loc []int) {
+	return re.firstIndex(readerInput(r), false)
+}
+
+// FindSubmatch returns a slice of slices holding the text of the leftmost
+// match of the regular expression in b and the matches, if any, of its
+// subexpressions.
+// A return value of nil indicates no match.
+func (re *Regexp) FindSubmatch(b []byte) [][]byte {
+	a := re.firstIndex(bytesInput(b), true)
+	if a == nil {
+		return nil
+	}
+	return bytesSubmatch(b, a)
+}
+
+func bytesSubmatch(b []byte, a []int) [][]byte {
+	ret := make([][]byte, len(a)/2)
+	for i := range ret {
+		if a[2*i] >= 0 {
+			ret[i] = b[a[2*i]:a[2*i+1]:a[2*i+1]]
+		}
+	}
+	return ret
+}
+
+// FindSubmatchIndex returns a slice holding the index pairs identifying the
+// leftmost match of the regular expression in b and the matches, if any, of
+// its subexpressions.
+// A return value of nil indicates no match.
+func (re *Regexp) FindSubmatchIndex(b []byte) []int {
+	return re.firstIndex(bytesInput(b), true)
+}
+
+// FindStringSubmatch returns a slice of strings holding the text of the
+// leftmost match of the regular expression in s and the matches, if any, of
+// its subexpressions.
+// A return value of nil indicates no match.
+func (re *Regexp) FindStringSubmatch(s string) []string {
+	a := re.firstIndex(stringInput(s), true)
+	if a == nil {
+		return nil
+	}
+	return stringSubmatch(s, a)
+}
+
+func stringSubmatch(s string, a []int) []string {
+	ret := make([]string, len(a)/2)
+	for i := range ret {
+		if a[2*i] >= 0 {
+			ret[i] = s[a[2*i]:a[2*i+1]]
+		}
+	}
+	return ret
+}
+
+// FindStringSubmatchIndex returns a slice holding the index pairs
+// identifying the leftmost match of the regular expression in s and the
+// matches, if any, of its subexpressions.
+// A return value of nil indicates no match.
+func (re *Regexp) FindStringSubmatchIndex(s string) []int {
+	return re.firstIndex(stringInput(s), true)
+}
+
+// FindReaderSubmatchIndex returns a slice holding the index pairs
+// identifying the leftmost match of the regular expression of text read by
+// the RuneReader, and the matches, if any, of its subexpressions.
+// A return value of nil indicates no match.
+func (re *Regexp) FindReaderSubmatchIndex(r io.RuneReader) []int {
+	return re.firstIndex(readerInput(r), true)
+}
+
+// FindAll is the 'All' version of Find; it returns a slice of all successive
+// matches of the expression.  A return value of nil indicates no match.
+func (re *Regexp) FindAll(b []byte, n int) [][]byte {
+	var result [][]byte
+	re.allIndex(bytesInput(b), n, false, func(a []int) {
+		result = append(result, b[a[0]:a[1]:a[1]])
+	})
+	return result
+}
+
+// FindAllIndex is the 'All' version of FindIndex; it returns a slice of all
+// successive matches of the expression.  A return value of nil indicates no match.
+func (re *Regexp) FindAllIndex(b []byte, n int) [][]int {
+	var result [][]int
+	re.allIndex(bytesInput(b), n, false, func(a []int) {
+		result = append(result, a)
+	})
+	return result
+}
+
+// FindAllString is the 'All' version of FindString; it returns a slice of all
+// successive matches of the expression.  A return value of nil indicates no match.
+func (re *Regexp) FindAllString(s string, n int) []string {
+	var result []string
+	re.allIndex(stringInput(s), n, false, func(a []int) {
+		result = append(result, s[a[0]:a[1]])
+	})
+	return result
+}
+
+// FindAllStringIndex is the 'All' version of FindStringIndex; it returns a
+// slice of all successive matches of the expression.  A return value of nil
+// indicates no match.
+func (re *Regexp) FindAllStringIndex(s string, n int) [][]int {
+	var result [][]int
+	re.allIndex(stringInput(s), n, false, func(a []int) {
+		result = append(result, a)
+	})
+	return result
+}
+
+// FindAllSubmatch is the 'All' version of FindSubmatch; it returns a slice
+// of all successive matches of the expression.  A return value of nil
+// indicates no match.
+func (re *Regexp) FindAllSubmatch(b []byte, n int) [][][]byte {
+	var result [][][]byte
+	re.allIndex(bytesInput(b), n, true, func(a []int) {
+		result = append(result, bytesSubmatch(b, a))
+	})
+	return result
+}
+
+// FindAllSubmatchIndex is the 'All' version of FindSubmatchIndex; it returns
+// a slice of all successive matches of the expression.  A return value of nil
+// indicates no match.
+func (re *Regexp) FindAllSubmatchIndex(b []byte, n int) [][]int {
+	var result [][]int
+	re.allIndex(bytesInput(b), n, true, func(a []int) {
+		result = append(result, a)
+	})
+	return result
+}
+
+// FindAllStringSubmatch is the 'All' version of FindStringSubmatch; it
+// returns a slice of all successive matches of the expression.  A return
+// value of nil indicates no match.
+func (re *Regexp) FindAllStringSubmatch(s string, n int) [][]string {
+	var result [][]string
+	re.allIndex(stringInput(s), n, true, func(a []int) {
+		result = append(result, stringSubmatch(s, a))
+	})
+	return result
+}
+
+// FindAllStringSubmatchIndex is the 'All' version of FindStringSubmatchIndex;
+// it returns a slice of all successive matches of the expression.  A return
+// value of nil indicates no match.
+func (re *Regexp) FindAllStringSubmatchIndex(s string, n int) [][]int {
+	var result [][]int
+	re.allIndex(stringInput(s), n, true, func(a []int) {
+		result = append(result, a)
+	})
+	return result
+}
+
+// Split slices s into substrings separated by the expression and returns a slice of
+// the substrings between those expression matches.
+//
+// The count determines the number of substrings to return:
+//
+//	n > 0: at most n substrings; the last substring will be the unsplit remainder.
+//	n == 0: the result is nil (zero substrings)
+//	n < 0: all substrings
+func (re *Regexp) Split(s string, n int) []string {
+	if n == 0 {
+		return nil
+	}
+
+	if len(re.expr) > 0 && len(s) == 0 {
+		return []string{""}
+	}
+
+	matches := re.FindAllStringIndex(s, n)
+	strs := make([]string, 0, len(matches))
+
+	beg := 0
+	end := 0
+	for _, match := range matches {
+		if n > 0 && len(strs) == n-1 {
+			break
+		}
+
+		end = match[0]
+		if match[1] != 0 {
+			strs = append(strs, s[beg:end])
+		}
+		beg = match[1]
+	}
+
+	if end != len(s) {
+		strs = append(strs, s[beg:])
+	}
+
+	return strs
+}
+
+// replaceAll calls repl for each match of re in in, appending its output and the
+// text between the matches to the result.  Like the regexp package it doesn't
+// replace an empty match right after another match.
+func (re *Regexp) replaceAll(in input, text func(dst []byte, beg, end int) []byte, groups bool, repl func(dst []byte, a []int) []byte) []byte {
+	var buf []byte
+	lastMatchEnd := 0 // rune index of the end of the most recent match
+	end := len(in.runes)
+	for searchPos := 0; searchPos <= end; {
+		m := re.find(in, searchPos)
+		if m == nil {
+			break
+		}
+
+		// copy the unmatched text before this match
+		buf = text(buf, in.offsets[lastMatchEnd], in.offsets[m.Index])
+
+		matchEnd := m.Index + m.Length
+		if matchEnd > lastMatchEnd || m.Index == 0 {
+			buf = repl(buf, re.submatchIndex(in, m, groups))
+		}
+		lastMatchEnd = matchEnd
+
+		// advance past this match, always by at least one rune
+		if searchPos+1 > matchEnd {
+			searchPos++
+		} else {
+			searchPos = matchEnd
+		}
+	}
+
+	return text(buf, in.offsets[lastMatchEnd], in.offsets[end])
+}
+
+// ReplaceAllString returns a copy of src, replacing matches of the Regexp
+// with the replacement string repl.
+// Inside repl, $ signs are interpreted as in Expand.
+func (re *Regexp) ReplaceAllString(src, repl string) string {
+	groups := strings.Contains(repl, "$")
+	b := re.replaceAll(stringInput(src), stringText(src), groups, func(dst []byte, a []int) []byte {
+		return re.expand(dst, repl, nil, src, a)
+	})
+	return string(b)
+}
+
+// ReplaceAllLiteralString returns a copy of src, replacing matches of the Regexp
+// with the replacement string repl.  The replacement repl is substituted directly,
+// without using Expand.
+func (re *Regexp) ReplaceAllLiteralString(src, repl string) string {
+	return string(re.replaceAll(stringInput(src), stringText(src), false, func(dst []byte, a []int) []byte {
+		return append(dst, repl...)
+	}))
+}
+
+// ReplaceAllStringFunc returns a copy of src in which all matches of the
+// Regexp have been replaced by the return value of function repl applied
+// to the matched substring.  The replacement returned by repl is substituted
+// directly, without using Expand.
+func (re *Regexp) ReplaceAllStringFunc(src string, repl func(string) string) string {
+	return string(re.replaceAll(stringInput(src), stringText(src), false, func(dst []byte, a []int) []byte {
+		return append(dst, repl(src[a[0]:a[1]])...)
+	}))
+}
+
+// ReplaceAll returns a copy of src, replacing matches of the Regexp
+// with the replacement text repl.
+// Inside repl, $ signs are interpreted as in Expand.
+func (re *Regexp) ReplaceAll(src, repl []byte) []byte {
+	groups := bytes.IndexByte(repl, '$') >= 0
+	srepl := string(repl)
+	return re.replaceAll(bytesInput(src), bytesText(src), groups, func(dst []byte, a []int) []byte {
+		return re.expand(dst, srepl, src, "", a)
+	})
+}
+
+// ReplaceAllLiteral returns a copy of src, replacing matches of the Regexp
+// with the replacement bytes repl.  The replacement repl is substituted directly,
+// without using Expand.
+func (re *Regexp) ReplaceAllLiteral(src, repl []byte) []byte {
+	return re.replaceAll(bytesInput(src), bytesText(src), false, func(dst []byte, a []int) []byte {
+		return append(dst, repl...)
+	})
+}
+
+// ReplaceAllFunc returns a copy of src in which all matches of the
+// Regexp have been replaced by the return value of function repl applied
+// to the matched byte slice.  The replacement returned by repl is substituted
+// directly, without using Expand.
+func (re *Regexp) ReplaceAllFunc(src []byte, repl func([]byte) []byte) []byte {
+	return re.replaceAll(bytesInput(src), bytesText(src), false, func(dst []byte, a []int) []byte {
+		return append(dst, repl(src[a[0]:a[1]])...)
+	})
+}
+
+func stringText(s string) func([]byte, int, int) []byte {
+	return func(dst []byte, beg, end int) []byte {
+		return append(dst, s[beg:end]...)
+	}
+}
+
+func bytesText(b []byte) func([]byte, int, int) []byte {
+	return func(dst []byte, beg, end int) []byte {
+		return append(dst, b[beg:end]...)
+	}
+}
+
+// Expand appends template to dst and returns the result; during the
+// append, Expand replaces variables in the template with corresponding
+// matches drawn from src.  The match slice should have been returned by
+// FindSubmatchIndex.
+//
+// In the template, a variable is denoted by a substring of the form
+// $name or ${name}, where name is a non-empty sequence of letters,
+// digits, and underscores.  A purely numeric name like $1 refers to
+// the submatch with the corresponding index; other names refer to
+// capturing parentheses named with the (?P<name>...) syntax.  A
+// reference to an out of range or unmatched index or a name that is not
+// present in the regular expression is replaced with an empty slice.
+//
+// In the $name form, name is taken to be as long as possible: $1x is
+// equivalent to ${1x}, not ${1}x, and, $10 is equivalent to ${10}, not ${1}0.
+//
+// To insert a literal $ in the output, use $$ in the template.
+func (re *Regexp) Expand(dst []byte, template []byte, src []byte, match []int) []byte {
+	return re.expand(dst, string(template), src, "", match)
+}
+
+// ExpandString is like Expand but the template and source are strings.
+// It appends to and returns a byte slice in order to give the calling
+// code control over allocation.
+func (re *Regexp) ExpandString(dst []byte, template string, src string, match []int) []byte {
+	return re.expand(dst, template, nil, src, match)
+}
+
+func (re *Regexp) expand(dst []byte, template string, bsrc []byte, src string, match []int) []byte {
+	for len(template) > 0 {
+		i := strings.IndexByte(template, '$')
+		if i < 0 {
+			break
+		}
+		dst = append(dst, template[:i]...)
+		template = template[i+1:]
+		if len(template) > 0 && template[0] == '$' {
+			// $$ is a literal $
+			dst = append(dst, '$')
+			template = template[1:]
+			continue
+		}
+
+		name, num, rest, ok := extract(template)
+		if !ok {
+			// malformed; treat the $ as raw text
+			dst = append(dst, '$')
+			continue
+		}
+		template = rest
+
+		if num < 0 {
+			num = re.SubexpIndex(name)
+			if num < 0 {
+				continue
+			}
+		}
+		if 2*num+1 < len(match) && match[2*num] >= 0 {
+			if bsrc != nil {
+				dst = append(dst, bsrc[match[2*num]:match[2*num+1]]...)
+			} else {
+				dst = append(dst, src[match[2*num]:match[2*num+1]]...)
+			}
+		}
+	}
+	return append(dst, template...)
+}
+
+// extract returns the name from a leading "name" or "{name}" in str, along with
+// its number if the name is a number without a leading zero, or -1
+func extract(str string) (name string, num int, rest string, ok bool) {
+	if str == "" {
+		return
+	}
+	brace := false
+	if str[0] == '{' {
+		brace = true
+		str = str[1:]
+	}
+	i := 0
+	for i < len(str) {
+		r, size := utf8.DecodeRuneInString(str[i:])
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			break
+		}
+		i += size
+	}
+	if i == 0 {
+		// empty name is not okay
+		return
+	}
+	name = str[:i]
+	if brace {
+		if i >= len(str) || str[i] != '}' {
+			// missing closing brace
+			return
+		}
+		i++
+	}
+
+	num = 0
+	for j := 0; j < len(name); j++ {
+		if name[j] < '0' || '9' < name[j] || num >= 1e8 {
+			num = -1
+			break
+		}
+		num = num*10 + int(name[j]) - '0'
+	}
+	if name[0] == '0' && len(name) > 1 {
+		// leading zeros aren't allowed
+		num = -1
+	}
+
+	return name, num, str[i:], true
+}
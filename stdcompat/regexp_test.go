@@ -0,0 +1,283 @@
+package stdcompat
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dlclark/regexp2"
+)
+
+var compatTests = []struct {
+	pattern string
+	input   string
+}{
+	{`a+`, "baaac aa"},
+	{`a*`, "baaac"},
+	{`x*`, "héllo"},
+	{`(a)|(b)`, "abc"},
+	{`(?P<first>\w+)\s(?P<last>\w+)`, "Ada Lovelace, Alan Turing"},
+	{`(a)(?P<n>b)(c)`, "xabcx"},
+	{`(?P<x>a)(b)`, "ab ab"},
+	{`é+`, "aééb é"},
+	{`[^a]`, "日本語a"},
+	{`\bfoo\b`, "foo foobar foo"},
+	{`(a|ab)(c|bcd)(d*)`, "abcd"},
+	{`^`, "abc"},
+	{`$`, "abc"},
+	{`(?m)^\w`, "ab\ncd\nef"},
+	{`(\d+)-(\d+)?`, "1-2 3- 45-6"},
+	{`b*`, "abba"},
+	{`abc`, ""},
+	{``, "ab"},
+	{`.`, "é日本"},
+	{`\p{L}+`, "héllo wörld"},
+	{`[à-ü]+(x)?`, "héllo wörld"},
+	{`(?i)é`, "éÉe"},
+	{`[^\x00-\x7f]+`, "naïve café"},
+}
+
+func TestCompat_Find(t *testing.T) {
+	for _, tt := range compatTests {
+		std := regexp.MustCompile(tt.pattern)
+		re := MustCompile(tt.pattern)
+		b := []byte(tt.input)
+
+		check := func(name string, want, got interface{}) {
+			t.Helper()
+			if !reflect.DeepEqual(want, got) {
+				t.Errorf("%v %q on %q:\nWanted '%#v'\nGot '%#v'", name, tt.pattern, tt.input, want, got)
+			}
+		}
+
+		check("MatchString", std.MatchString(tt.input), re.MatchString(tt.input))
+		check("Match", std.Match(b), re.Match(b))
+		check("FindString", std.FindString(tt.input), re.FindString(tt.input))
+		check("FindStringIndex", std.FindStringIndex(tt.input), re.FindStringIndex(tt.input))
+		check("Find", std.Find(b), re.Find(b))
+		check("FindIndex", std.FindIndex(b), re.FindIndex(b))
+		check("FindStringSubmatch", std.FindStringSubmatch(tt.input), re.FindStringSubmatch(tt.input))
+		check("FindStringSubmatchIndex", std.FindStringSubmatchIndex(tt.input), re.FindStringSubmatchIndex(tt.input))
+		check("FindSubmatch", std.FindSubmatch(b), re.FindSubmatch(b))
+		check("FindSubmatchIndex", std.FindSubmatchIndex(b), re.FindSubmatchIndex(b))
+		check("FindReaderIndex", std.FindReaderIndex(strings.NewReader(tt.input)), re.FindReaderIndex(strings.NewReader(tt.input)))
+		check("FindReaderSubmatchIndex", std.FindReaderSubmatchIndex(strings.NewReader(tt.input)), re.FindReaderSubmatchIndex(strings.NewReader(tt.input)))
+		check("MatchReader", std.MatchReader(strings.NewReader(tt.input)), re.MatchReader(strings.NewReader(tt.input)))
+
+		for _, n := range []int{-1, 0, 1, 2} {
+			check("FindAllString", std.FindAllString(tt.input, n), re.FindAllString(tt.input, n))
+			check("FindAllStringIndex", std.FindAllStringIndex(tt.input, n), re.FindAllStringIndex(tt.input, n))
+			check("FindAll", std.FindAll(b, n), re.FindAll(b, n))
+			check("FindAllIndex", std.FindAllIndex(b, n), re.FindAllIndex(b, n))
+			check("FindAllStringSubmatch", std.FindAllStringSubmatch(tt.input, n), re.FindAllStringSubmatch(tt.input, n))
+			check("FindAllStringSubmatchIndex", std.FindAllStringSubmatchIndex(tt.input, n), re.FindAllStringSubmatchIndex(tt.input, n))
+			check("FindAllSubmatch", std.FindAllSubmatch(b, n), re.FindAllSubmatch(b, n))
+			check("FindAllSubmatchIndex", std.FindAllSubmatchIndex(b, n), re.FindAllSubmatchIndex(b, n))
+			check("Split", std.Split(tt.input, n), re.Split(tt.input, n))
+		}
+	}
+}
+
+func TestCompat_Replace(t *testing.T) {
+	repls := []string{"", "x", "<$0>", "$1$2", "${1}x", "$1x", "$$", "$first-${last}", "$n", "$", "${", "$9"}
+	for _, tt := range compatTests {
+		std := regexp.MustCompile(tt.pattern)
+		re := MustCompile(tt.pattern)
+		b := []byte(tt.input)
+
+		for _, repl := range repls {
+			if want, got := std.ReplaceAllString(tt.input, repl), re.ReplaceAllString(tt.input, repl); want != got {
+				t.Errorf("ReplaceAllString %q on %q with %q:\nWanted '%v'\nGot '%v'", tt.pattern, tt.input, repl, want, got)
+			}
+			if want, got := std.ReplaceAll(b, []byte(repl)), re.ReplaceAll(b, []byte(repl)); string(want) != string(got) {
+				t.Errorf("ReplaceAll %q on %q with %q:\nWanted '%s'\nGot '%s'", tt.pattern, tt.input, repl, want, got)
+			}
+			if want, got := std.ReplaceAllLiteralString(tt.input, repl), re.ReplaceAllLiteralString(tt.input, repl); want != got {
+				t.Errorf("ReplaceAllLiteralString %q on %q with %q:\nWanted '%v'\nGot '%v'", tt.pattern, tt.input, repl, want, got)
+			}
+			if want, got := std.ReplaceAllLiteral(b, []byte(repl)), re.ReplaceAllLiteral(b, []byte(repl)); string(want) != string(got) {
+				t.Errorf("ReplaceAllLiteral %q on %q with %q:\nWanted '%s'\nGot '%s'", tt.pattern, tt.input, repl, want, got)
+			}
+		}
+
+		upper := func(s string) string { return "[" + strings.ToUpper(s) + "]" }
+		if want, got := std.ReplaceAllStringFunc(tt.input, upper), re.ReplaceAllStringFunc(tt.input, upper); want != got {
+			t.Errorf("ReplaceAllStringFunc %q on %q:\nWanted '%v'\nGot '%v'", tt.pattern, tt.input, want, got)
+		}
+		bupper := func(s []byte) []byte { return []byte(upper(string(s))) }
+		if want, got := std.ReplaceAllFunc(b, bupper), re.ReplaceAllFunc(b, bupper); string(want) != string(got) {
+			t.Errorf("ReplaceAllFunc %q on %q:\nWanted '%s'\nGot '%s'", tt.pattern, tt.input, want, got)
+		}
+	}
+}
+
+func TestCompat_Subexp(t *testing.T) {
+	for _, pattern := range []string{`a`, `(a)(?P<n>b)(c)`, `(?P<x>a)((b)(?P<y>c))`, `(?:a)(b)`} {
+		std := regexp.MustCompile(pattern)
+		re := MustCompile(pattern)
+
+		if want, got := std.NumSubexp(), re.NumSubexp(); want != got {
+			t.Errorf("NumSubexp %q:\nWanted '%v'\nGot '%v'", pattern, want, got)
+		}
+		if want, got := std.SubexpNames(), re.SubexpNames(); !reflect.DeepEqual(want, got) {
+			t.Errorf("SubexpNames %q:\nWanted '%#v'\nGot '%#v'", pattern, want, got)
+		}
+		for _, name := range []string{"", "n", "x", "y", "z"} {
+			if want, got := std.SubexpIndex(name), re.SubexpIndex(name); want != got {
+				t.Errorf("SubexpIndex %q %q:\nWanted '%v'\nGot '%v'", pattern, name, want, got)
+			}
+		}
+	}
+}
+
+func TestCompat_LiteralPrefix(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		opt      regexp2.RegexOptions
+		prefix   string
+		complete bool
+	}{
+		{`abc`, regexp2.RE2, "abc", true},
+		{`ab(c)`, regexp2.RE2, "abc", true},
+		{``, regexp2.RE2, "", true},
+		{`abc+`, regexp2.RE2, "ab", false},
+		{`a|b`, regexp2.RE2, "", false},
+		{`^abc`, regexp2.RE2, "abc", false},
+		{`ab(?=c)`, regexp2.RE2, "ab", false},
+		{`(?i)abc`, regexp2.RE2, "", false},
+		{`abc`, regexp2.IgnoreCase, "", false},
+		{`a b c`, regexp2.IgnorePatternWhitespace, "abc", true},
+		{`abc`, regexp2.RightToLeft, "", false},
+	}
+	for _, tt := range tests {
+		prefix, complete := Wrap(regexp2.MustCompile(tt.pattern, tt.opt)).LiteralPrefix()
+		if tt.prefix != prefix || tt.complete != complete {
+			t.Errorf("LiteralPrefix %q:\nWanted '%v %v'\nGot '%v %v'", tt.pattern, tt.prefix, tt.complete, prefix, complete)
+		}
+
+		// any match has to begin with the prefix
+		if std, err := regexp.Compile(tt.pattern); err == nil && tt.opt == regexp2.RE2 {
+			if stdPrefix, _ := std.LiteralPrefix(); !strings.HasPrefix(stdPrefix, prefix) {
+				t.Errorf("LiteralPrefix %q: '%v' isn't a prefix of '%v'", tt.pattern, prefix, stdPrefix)
+			}
+		}
+	}
+}
+
+func TestCompat_Expand(t *testing.T) {
+	std := regexp.MustCompile(`(?P<key>\w+):\s*(?P<value>\w+)`)
+	re := MustCompile(`(?P<key>\w+):\s*(?P<value>\w+)`)
+	src := "a: 1, bb: 22"
+	template := "$value=$key;${1}${2}$3$$"
+
+	for _, m := range re.FindAllStringSubmatchIndex(src, -1) {
+		want := string(std.ExpandString(nil, template, src, m))
+		if got := string(re.ExpandString(nil, template, src, m)); want != got {
+			t.Fatalf("Wanted '%v'\nGot '%v'", want, got)
+		}
+		if got := string(re.Expand([]byte("pre "), []byte(template), []byte(src), m)); "pre "+want != got {
+			t.Fatalf("Wanted '%v'\nGot '%v'", "pre "+want, got)
+		}
+	}
+}
+
+func TestCompat_Longest(t *testing.T) {
+	re := MustCompile(`a(|b)`)
+	cp := re.Copy()
+	re.Longest()
+
+	if want, got := "ab", re.FindString("ab"); want != got {
+		t.Fatalf("Wanted '%v'\nGot '%v'", want, got)
+	}
+	if want, got := "a", cp.FindString("ab"); want != got {
+		t.Fatalf("Copy affected by Longest\nWanted '%v'\nGot '%v'", want, got)
+	}
+	if want, got := "ab", MustCompilePOSIX(`a(|b)`).FindString("ab"); want != got {
+		t.Fatalf("Wanted '%v'\nGot '%v'", want, got)
+	}
+}
+
+func TestCompat_UnicodeClasses(t *testing.T) {
+	// \w, \d, \s and \b are Unicode-aware, unlike regexp's ASCII-only classes
+	tests := []struct {
+		pattern, input string
+		std, want      [][]int
+	}{
+		{`\b\w`, "héllo éé wörld", [][]int{{0, 1}, {3, 4}, {12, 13}, {15, 16}}, [][]int{{0, 1}, {7, 9}, {12, 13}}},
+		{`\w+`, "héllo", [][]int{{0, 1}, {3, 6}}, [][]int{{0, 6}}},
+		{`\d`, "1٣2", [][]int{{0, 1}, {3, 4}}, [][]int{{0, 1}, {1, 3}, {3, 4}}},
+		{`\s`, "a\u00a0b", nil, [][]int{{1, 3}}},
+	}
+	for _, tt := range tests {
+		if got := regexp.MustCompile(tt.pattern).FindAllStringIndex(tt.input, -1); !reflect.DeepEqual(tt.std, got) {
+			t.Errorf("regexp %q on %q: Wanted '%v'\nGot '%v'", tt.pattern, tt.input, tt.std, got)
+		}
+		if got := MustCompile(tt.pattern).FindAllStringIndex(tt.input, -1); !reflect.DeepEqual(tt.want, got) {
+			t.Errorf("%q on %q: Wanted '%v'\nGot '%v'", tt.pattern, tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestCompat_Text(t *testing.T) {
+	re := MustCompile(`a(b)`)
+	text, err := re.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var re2 Regexp
+	if err := re2.UnmarshalText(text); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := re.String(), re2.String(); want != got {
+		t.Fatalf("Wanted '%v'\nGot '%v'", want, got)
+	}
+	if text, err := re.AppendText([]byte("x")); err != nil || string(text) != "x"+re.String() {
+		t.Fatalf("Wanted '%v'\nGot '%s' %v", "x"+re.String(), text, err)
+	}
+	if want, got := "b", re2.FindStringSubmatch("xab")[1]; want != got {
+		t.Fatalf("Wanted '%v'\nGot '%v'", want, got)
+	}
+	if err := re2.UnmarshalText([]byte(`a(`)); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestCompat_PackageFuncs(t *testing.T) {
+	if ok, err := MatchString(`\d+`, "ab12"); err != nil || !ok {
+		t.Fatalf("MatchString: %v %v", ok, err)
+	}
+	if ok, err := Match(`\d+`, []byte("ab")); err != nil || ok {
+		t.Fatalf("Match: %v %v", ok, err)
+	}
+	if ok, err := MatchReader(`b`, strings.NewReader("ab")); err != nil || !ok {
+		t.Fatalf("MatchReader: %v %v", ok, err)
+	}
+	if _, err := MatchString(`(`, "a"); err == nil {
+		t.Fatal("expected error")
+	}
+	if !MustCompile(QuoteMeta("a.b*[c]")).MatchString("xa.b*[c]") {
+		t.Fatal("QuoteMeta result didn't match")
+	}
+}
+
+func TestCompat_Timeout(t *testing.T) {
+	inner := regexp2.MustCompile(`(a+)+$`, regexp2.RE2)
+	inner.MatchTimeout = 10 * time.Millisecond
+	re := Wrap(inner)
+
+	defer func() {
+		r := recover()
+		terr, ok := r.(*TimeoutError)
+		if !ok {
+			t.Fatalf("Wanted a *TimeoutError panic\nGot '%v'", r)
+		}
+		if terr.Error() == "" || errors.Unwrap(terr) == nil {
+			t.Fatalf("TimeoutError doesn't carry the engine error: %#v", terr)
+		}
+	}()
+	re.MatchString(strings.Repeat("a", 50) + "b")
+}
@@ -0,0 +1,253 @@
+package regexp2
+
+import (
+	"bufio"
+	"errors"
+	"io"
+
+	"github.com/dlclark/regexp2/syntax"
+)
+
+// streamReadSize is the number of runes read from the underlying reader at a time
+const streamReadSize = 4096
+
+// StreamMatch is a match found by a ReaderScanner.  The embedded Match refers to a
+// window of the stream, so its Index and the positions of its groups and captures are
+// relative to that window.  Add Offset to convert them to absolute rune offsets.
+type StreamMatch struct {
+	*Match
+
+	// Offset is the absolute position in the stream, in runes, of the window the
+	// match's positions are relative to.
+	Offset int
+}
+
+// AbsIndex returns the absolute position in the stream, in runes, where the match starts
+func (m *StreamMatch) AbsIndex() int {
+	return m.Offset + m.Index
+}
+
+// ReaderScanner finds successive matches of a Regexp in text read from an io.Reader.
+// Only the text needed by the current match attempt and the lookbehind the pattern
+// requires is buffered.  A ReaderScanner is not safe for concurrent use.
+type ReaderScanner struct {
+	re         *Regexp
+	rd         io.RuneReader
+	lookbehind int
+	usesStart  bool // \G ties matches to the search position
+
+	buf  []rune // window of the stream
+	base int    // absolute position of buf[0]
+	pos  int    // where the next search starts, relative to buf
+	eof  bool
+	done bool
+	err  error
+
+	// optional UTF-8 sizes of the runes in buf, for reporting byte offsets
+	trackBytes bool
+	sizes      []uint8
+	byteBase   int // byte offset of buf[0]
+}
+
+// NewReaderScanner returns a scanner that finds the matches of re in the text read from rd.
+// Invalid UTF-8 is read as utf8.RuneError just like converting a string to runes.
+// It returns an error for RightToLeft patterns and for patterns whose lookbehind isn't
+// bounded, since those would need the whole stream in memory.
+func (re *Regexp) NewReaderScanner(rd io.Reader) (*ReaderScanner, error) {
+	if re.RightToLeft() {
+		return nil, errors.New("regexp2: RightToLeft patterns can't be used on a stream")
+	}
+	if !re.canScanStream() {
+		return nil, errors.New("regexp2: pattern requires unbounded lookbehind and can't be used on a stream")
+	}
+
+	rr, ok := rd.(io.RuneReader)
+	if !ok {
+		rr = bufio.NewReader(rd)
+	}
+	return re.newReaderScanner(rr), nil
+}
+
+// canScanStream tells if the pattern can be matched with bounded buffering
+func (re *Regexp) canScanStream() bool {
+	return !re.RightToLeft() && re.code.Lookbehind >= 0
+}
+
+func (re *Regexp) newReaderScanner(rr io.RuneReader) *ReaderScanner {
+	// always keep one rune before the search position so \A and
+	// friends know the window doesn't start at the beginning of the stream
+	lookbehind := re.code.Lookbehind
+	if lookbehind < 1 {
+		lookbehind = 1
+	}
+
+	return &ReaderScanner{
+		re:         re,
+		rd:         rr,
+		lookbehind: lookbehind,
+		usesStart:  re.code.UsesOp(syntax.Start),
+	}
+}
+
+// Next returns the next match in the stream.  It returns nil once the stream is
+// exhausted.  Read errors and match timeouts are returned as errors, after which
+// the scanner stops.
+func (s *ReaderScanner) Next() (*StreamMatch, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.done {
+		return nil, nil
+	}
+
+	for {
+		if !s.eof && s.pos >= len(s.buf) {
+			if err := s.fill(); err != nil {
+				s.err = err
+				return nil, err
+			}
+			continue
+		}
+
+		m, hitEnd, hitStart, err := s.search()
+		if err != nil {
+			s.err = err
+			return nil, err
+		}
+
+		if s.eof || !hitEnd {
+			if m != nil {
+				// more text can't change this match
+				ret := &StreamMatch{Match: m, Offset: s.base}
+				if s.pos = s.re.nextMatchStart(m); s.pos < 0 {
+					s.done = true
+				}
+				return ret, nil
+			}
+			if s.eof {
+				s.done = true
+				return nil, nil
+			}
+			if !s.usesStart {
+				// nothing can start in the current window whatever follows it
+				s.pos = len(s.buf)
+			} else if s.re.code.Anchors&syntax.AnchorStart != 0 {
+				// every match has to start at \G, which was in this window
+				s.done = true
+				return nil, nil
+			}
+			// otherwise \G stays at the search position, which can't move
+		} else if hitStart > s.pos && !s.usesStart {
+			// attempts before hitStart failed without needing more text
+			s.pos = hitStart
+		}
+
+		if err := s.fill(); err != nil {
+			s.err = err
+			return nil, err
+		}
+	}
+}
+
+// search looks for a match in the current window, reporting if the result
+// depends on text that hasn't been read yet and the first position that did
+func (s *ReaderScanner) search() (m *Match, hitEnd bool, hitStart int, err error) {
+	r := s.re.getRunner()
+	defer s.re.putRunner(r)
+
+	r.runtrackhitend = true
+	m, err = r.scan(s.buf, s.pos, -1, false, s.re.MatchTimeout)
+	return m, r.runhitend, r.runhitstart, err
+}
+
+// fill reads more of the stream into the window, dropping text before the
+// search position that lookbehind can no longer reach.
+func (s *ReaderScanner) fill() error {
+	drop := s.pos - s.lookbehind
+	if drop < 0 {
+		drop = 0
+	}
+
+	if cap(s.buf)-len(s.buf) < streamReadSize {
+		// reallocate rather than shift in place, returned matches still refer to the old window
+		size := 2 * (len(s.buf) - drop)
+		if size < streamReadSize*2 {
+			size = streamReadSize * 2
+		}
+		buf := make([]rune, len(s.buf)-drop, size)
+		copy(buf, s.buf[drop:])
+		s.buf = buf
+		s.base += drop
+		s.pos -= drop
+
+		if s.trackBytes {
+			s.byteBase = s.byteOffset(drop)
+			s.sizes = append(make([]uint8, 0, size), s.sizes[drop:]...)
+		}
+	}
+
+	for i := 0; i < streamReadSize; i++ {
+		ch, size, err := s.rd.ReadRune()
+		if err == io.EOF {
+			s.eof = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		s.buf = append(s.buf, ch)
+		if s.trackBytes {
+			s.sizes = append(s.sizes, uint8(size))
+		}
+	}
+	return nil
+}
+
+// byteOffset converts a position in the window to a byte offset in the stream
+func (s *ReaderScanner) byteOffset(pos int) int {
+	offset := s.byteBase
+	for _, size := range s.sizes[:pos] {
+		offset += int(size)
+	}
+	return offset
+}
+
+// findReaderIndex returns the byte offsets of the leftmost match in the text read from rr
+func (re *Regexp) findReaderIndex(rr io.RuneReader) ([]int, error) {
+	if !re.canScanStream() {
+		// the whole text is needed anyway
+		return re.findReaderIndexAll(rr)
+	}
+
+	s := re.newReaderScanner(rr)
+	s.trackBytes = true
+	m, err := s.Next()
+	if err != nil || m == nil {
+		return nil, err
+	}
+	return []int{s.byteOffset(m.Index), s.byteOffset(m.Index + m.Length)}, nil
+}
+
+func (re *Regexp) findReaderIndexAll(rr io.RuneReader) ([]int, error) {
+	var (
+		text    []rune
+		offsets = []int{0}
+	)
+	for {
+		ch, size, err := rr.ReadRune()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		text = append(text, ch)
+		offsets = append(offsets, offsets[len(offsets)-1]+size)
+	}
+
+	m, err := re.FindRunesMatch(text)
+	if err != nil || m == nil {
+		return nil, err
+	}
+	return []int{offsets[m.Index], offsets[m.Index+m.Length]}, nil
+}
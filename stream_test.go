@@ -0,0 +1,219 @@
+package regexp2
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestReaderScanner_SameAsSequential(t *testing.T) {
+	input := parallelTestInput(3 * streamReadSize)
+
+	tests := []struct {
+		pattern string
+		opt     RegexOptions
+	}{
+		{`a|ab`, 0},
+		{`abc`, 0},
+		{`\b\d{2,4}\b`, 0},
+		{`(?<=x)y`, 0},
+		{`(?<!a)b`, 0},
+		{``, 0},
+		{`x?`, 0},
+		{`^[a-c]{1,3}$`, Multiline},
+		{`\w+`, 0},
+		{`a+?b`, 0},
+		{`\Aab`, 0},
+		{`\d+\s*$`, 0},
+		{`(?i)AB(C)?`, 0},
+	}
+
+	for _, test := range tests {
+		re := MustCompile(test.pattern, test.opt)
+		want, err := re.findAllSequential([]rune(input))
+		if err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+
+		s, err := re.NewReaderScanner(iotest.HalfReader(strings.NewReader(input)))
+		if err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+		i := 0
+		for {
+			m, err := s.Next()
+			if err != nil {
+				t.Fatalf("Unexpected err: %v", err)
+			}
+			if m == nil {
+				break
+			}
+			if i >= len(want) {
+				t.Fatalf("%v: got more than the %v expected matches", test.pattern, len(want))
+			}
+			if want[i].Index != m.AbsIndex() || want[i].String() != m.String() {
+				t.Fatalf("%v: match %v wanted %q at %v, got %q at %v", test.pattern, i,
+					want[i].String(), want[i].Index, m.String(), m.AbsIndex())
+			}
+			i++
+		}
+		if i != len(want) {
+			t.Fatalf("%v: wanted %v matches, got %v", test.pattern, len(want), i)
+		}
+	}
+}
+
+func TestReaderScanner_BoundedBuffer(t *testing.T) {
+	input := strings.Repeat("x", 100*streamReadSize) + "yz"
+	for _, pattern := range []string{`yz`, `[yz]+`, `(?<=x)y`, `y|x{5}z`} {
+		re := MustCompile(pattern, 0)
+		s, err := re.NewReaderScanner(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+
+		m, err := s.Next()
+		if err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+		if m == nil {
+			t.Fatalf("%v: expected match", pattern)
+		}
+		if want, got := 100*streamReadSize, m.AbsIndex(); want != got {
+			t.Fatalf("%v: wanted match at %v, got %v", pattern, want, got)
+		}
+		if cap(s.buf) > 4*streamReadSize {
+			t.Fatalf("%v: expected buffer to stay bounded, grew to %v", pattern, cap(s.buf))
+		}
+	}
+}
+
+func TestReaderScanner_StartAnchorAcrossWindows(t *testing.T) {
+	input := "b" + strings.Repeat("x", streamReadSize-1) + "a"
+	for _, tt := range []struct {
+		pattern string
+		want    int
+	}{
+		{`\Ga`, -1},
+		{`\Gb`, 0},
+		{`\Ga|xa`, streamReadSize - 1},
+	} {
+		re := MustCompile(tt.pattern, 0)
+		s, err := re.NewReaderScanner(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+		m, err := s.Next()
+		if err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+		got := -1
+		if m != nil {
+			got = m.AbsIndex()
+		}
+		if tt.want != got {
+			t.Fatalf("%v: wanted match at %v, got %v", tt.pattern, tt.want, got)
+		}
+	}
+}
+
+func TestReaderScanner_Errors(t *testing.T) {
+	for _, pattern := range []string{`(?<=a+)b`, `(?<=\1)(a)`} {
+		if _, err := MustCompile(pattern, 0).NewReaderScanner(strings.NewReader("ab")); err == nil {
+			t.Fatalf("%v: expected unbounded lookbehind error", pattern)
+		}
+	}
+	if _, err := MustCompile(`a`, RightToLeft).NewReaderScanner(strings.NewReader("ab")); err == nil {
+		t.Fatalf("expected RightToLeft error")
+	}
+}
+
+func TestFindReaderIndex(t *testing.T) {
+	input := strings.Repeat("héllo wörld ", 1000) + "日本語 ünïcode 123"
+
+	tests := []struct {
+		pattern string
+		opt     RegexOptions
+		want    []int
+	}{
+		{`\d+`, 0, []int{len(input) - 3, len(input)}},
+		{`ünïcode`, 0, []int{strings.Index(input, "ünïcode"), strings.Index(input, "ünïcode") + len("ünïcode")}},
+		{`wörld`, 0, []int{7, 13}},
+		{`wörld`, RightToLeft, []int{strings.LastIndex(input, "wörld"), strings.LastIndex(input, "wörld") + len("wörld")}},
+		{`(?<=h.*)wörld`, 0, []int{7, 13}},
+		{`zzz`, 0, nil},
+	}
+
+	for _, test := range tests {
+		re := MustCompile(test.pattern, test.opt)
+		got, err := re.FindReaderIndex(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+		if want := test.want; fmt.Sprint(want) != fmt.Sprint(got) {
+			t.Fatalf("pattern %q: Wanted '%v'\nGot '%v'", test.pattern, want, got)
+		}
+
+		isMatch, err := re.MatchReader(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("Unexpected err: %v", err)
+		}
+		if want, got := test.want != nil, isMatch; want != got {
+			t.Fatalf("pattern %q: Wanted '%v'\nGot '%v'", test.pattern, want, got)
+		}
+	}
+}
+
+func TestFindReaderIndex_StartAnchor(t *testing.T) {
+	// \G stays at the start of the input rather than each window read
+	input := "b" + strings.Repeat("x", streamReadSize-1) + "a"
+	re := MustCompile(`\Ga`, 0)
+	loc, err := re.FindReaderIndex(strings.NewReader(input))
+	if err != nil || loc != nil {
+		t.Fatalf("Wanted no match, got %v %v", loc, err)
+	}
+	if isMatch, err := re.MatchReader(strings.NewReader(input)); err != nil || isMatch {
+		t.Fatalf("Wanted no match, got %v %v", isMatch, err)
+	}
+}
+
+// countingRuneReader counts the runes read from it
+type countingRuneReader struct {
+	io.RuneReader
+	n int
+}
+
+func (c *countingRuneReader) ReadRune() (rune, int, error) {
+	ch, size, err := c.RuneReader.ReadRune()
+	if err == nil {
+		c.n++
+	}
+	return ch, size, err
+}
+
+func TestMatchReader_ReadsLazily(t *testing.T) {
+	input := "abc" + strings.Repeat("x", 100*streamReadSize)
+	rd := &countingRuneReader{RuneReader: strings.NewReader(input)}
+
+	isMatch, err := MustCompile(`b`, 0).MatchReader(rd)
+	if err != nil {
+		t.Fatalf("Unexpected err: %v", err)
+	}
+	if !isMatch {
+		t.Fatalf("Expected match")
+	}
+	if rd.n > streamReadSize {
+		t.Fatalf("Read %v runes to find a match at the start of the input", rd.n)
+	}
+}
+
+func TestMatchReader_ReadError(t *testing.T) {
+	rd := bufio.NewReader(iotest.TimeoutReader(strings.NewReader(strings.Repeat("x", 2*streamReadSize))))
+	_, err := MustCompile(`y`, 0).MatchReader(rd)
+	if err != iotest.ErrTimeout {
+		t.Fatalf("Wanted '%v'\nGot '%v'", iotest.ErrTimeout, err)
+	}
+}
@@ -16,8 +16,26 @@ type CharSet struct {
 	sub        *CharSet //optional subtractor
 	negate     bool
 	anything   bool
+
+	// optional precomputed lookup, built once the set is final
+	ascii *asciiLookup
+}
+
+// asciiLookup caches the result of CharIn for every ASCII rune and, when it
+// doesn't depend on the rune, for every non-ASCII rune as well.
+type asciiLookup struct {
+	bits     [2]uint64
+	nonASCII nonASCIIResult
 }
 
+type nonASCIIResult int8
+
+const (
+	nonASCIIUnknown nonASCIIResult = iota // must evaluate the full set
+	nonASCIIAllIn                         // every rune >= 0x80 is in the set
+	nonASCIIAllOut                        // no rune >= 0x80 is in the set
+)
+
 type category struct {
 	negate bool
 	cat    string
@@ -223,7 +241,25 @@ func (c CharSet) mapHashFill(buf *bytes.Buffer) {
 
 // CharIn returns true if the rune is in our character set (either ranges or categories).
 // It handles negations and subtracted sub-charsets.
-func (c CharSet) CharIn(ch rune) bool {
+func (c *CharSet) CharIn(ch rune) bool {
+	if c.ascii != nil {
+		if ch >= 0 && ch < utf8.RuneSelf {
+			return c.ascii.bits[ch>>6]&(1<<uint(ch&63)) != 0
+		}
+		switch c.ascii.nonASCII {
+		case nonASCIIAllIn:
+			return true
+		case nonASCIIAllOut:
+			return false
+		}
+	}
+
+	return c.charInSlow(ch)
+}
+
+// charInSlow evaluates the ranges, categories and subtraction of the set
+// without consulting the ASCII lookup.
+func (c *CharSet) charInSlow(ch rune) bool {
 	val := false
 	// in s && !s.subtracted
 
@@ -280,13 +316,65 @@ func (c CharSet) CharIn(ch rune) bool {
 
 	// get subtracted recurse
 	if val && c.sub != nil {
-		val = !c.sub.CharIn(ch)
+		val = !c.sub.charInSlow(ch)
 	}
 
 	//log.Printf("Char '%v' in %v == %v", string(ch), c.String(), val)
 	return val
 }
 
+// buildASCIILookup precomputes CharIn for the ASCII range so the runner can skip
+// walking ranges and unicode tables for the common case.  The set must not be
+// modified afterwards.
+func (c *CharSet) buildASCIILookup() {
+	l := &asciiLookup{nonASCII: c.nonASCIIMembership()}
+	for ch := rune(0); ch < utf8.RuneSelf; ch++ {
+		if c.charInSlow(ch) {
+			l.bits[ch>>6] |= 1 << uint(ch&63)
+		}
+	}
+	c.ascii = l
+}
+
+// nonASCIIMembership determines if every rune >= 0x80 gets the same answer from
+// CharIn.  Only sets built purely from ranges can be decided; categories always
+// depend on the rune.
+func (c *CharSet) nonASCIIMembership() nonASCIIResult {
+	if len(c.categories) > 0 {
+		return nonASCIIUnknown
+	}
+
+	val := false
+	for _, r := range c.ranges {
+		if r.last < utf8.RuneSelf {
+			continue
+		}
+		if r.first > utf8.RuneSelf || r.last != utf8.MaxRune {
+			// only part of the non-ASCII runes are covered
+			return nonASCIIUnknown
+		}
+		val = true
+	}
+
+	if c.negate {
+		val = !val
+	}
+
+	if val && c.sub != nil {
+		switch c.sub.nonASCIIMembership() {
+		case nonASCIIAllIn:
+			val = false
+		case nonASCIIUnknown:
+			return nonASCIIUnknown
+		}
+	}
+
+	if val {
+		return nonASCIIAllIn
+	}
+	return nonASCIIAllOut
+}
+
 func (c category) String() string {
 	switch c.cat {
 	case spaceCategoryText:
@@ -100,6 +100,13 @@ type Code struct {
 	BmPrefix    *BmPrefix   // the fixed prefix string as a Boyer-Moore machine (may be null)
 	Anchors     AnchorLoc   // the set of zero-length start anchors (RegexFCD.Bol, etc)
 	RightToLeft bool        // true if right to left
+	MaxLength   int         // the most runes a match can span, -1 if unbounded
+	Lookbehind  int         // the most runes before a match's start it can examine, -1 if unbounded
+
+	// InLookaround tells if each code position is in a lookaround assertion, from
+	// the instruction after its Setjump to the end of its body.  It's nil if there
+	// are none
+	InLookaround []bool
 }
 
 func opcodeBacktracks(op InstOp) bool {
@@ -239,6 +246,49 @@ func (c *Code) OpcodeDescription(offset int) string {
 	return buf.String()
 }
 
+// UsesOp returns true if the operator appears anywhere in the code, ignoring modifiers
+func (c *Code) UsesOp(op InstOp) bool {
+	for i := 0; i < len(c.Codes); i += opcodeSize(InstOp(c.Codes[i])) {
+		if InstOp(c.Codes[i])&Mask == op {
+			return true
+		}
+	}
+	return false
+}
+
+// LiteralPrefix returns the literal that every match begins with, and true if
+// the code matches only that literal
+func (c *Code) LiteralPrefix() (prefix []rune, complete bool) {
+	if c.RightToLeft {
+		return nil, false
+	}
+
+	complete = true
+	for i := 0; i < len(c.Codes) && complete; i += opcodeSize(InstOp(c.Codes[i])) {
+		// the exact ops, so case-insensitive literals don't count
+		switch InstOp(c.Codes[i]) {
+		case One:
+			prefix = append(prefix, rune(c.Codes[i+1]))
+		case Multi:
+			prefix = append(prefix, c.Strings[c.Codes[i+1]]...)
+		case Setmark, Capturemark, Stop:
+		case Lazybranch:
+			// only the branch around the whole pattern
+			complete = i == 0
+		default:
+			complete = false
+		}
+	}
+	if complete {
+		return prefix, true
+	}
+
+	if c.BmPrefix == nil || c.BmPrefix.CaseInsensitive() {
+		return nil, false
+	}
+	return c.BmPrefix.Pattern(), false
+}
+
 func (c *Code) Dump() string {
 	buf := &bytes.Buffer{}
 
@@ -260,6 +310,11 @@ func (c *Code) Dump() string {
 	}
 
 	fmt.Fprintf(buf, "Anchors:    %v\n", c.Anchors)
+	if c.MaxLength < 0 {
+		fmt.Fprintln(buf, "MaxLength:  inf")
+	} else {
+		fmt.Fprintf(buf, "MaxLength:  %v\n", c.MaxLength)
+	}
 	fmt.Fprintln(buf)
 
 	if c.BmPrefix != nil {
@@ -0,0 +1,101 @@
+package syntax
+
+import "math"
+
+// maxLength returns the largest number of runes a match of the node can
+// consume, or -1 if there is no upper bound.  Lookarounds are zero width and
+// backreferences are treated as unbounded.
+func (n *regexNode) maxLength() int {
+	switch n.t {
+	case ntOne, ntNotone, ntSet:
+		return 1
+
+	case ntMulti:
+		return len(n.str)
+
+	case ntOneloop, ntNotoneloop, ntSetloop, ntOnelazy, ntNotonelazy, ntSetlazy:
+		if n.n == math.MaxInt32 {
+			return -1
+		}
+		return n.n
+
+	case ntRef:
+		return -1
+
+	case ntBol, ntEol, ntBoundary, ntNonboundary, ntECMABoundary, ntNonECMABoundary,
+		ntBeginning, ntStart, ntEndZ, ntEnd, ntNothing, ntEmpty, ntRequire, ntPrevent:
+		return 0
+
+	case ntAlternate, ntTestref, ntTestgroup:
+		max := 0
+		for _, child := range n.children {
+			l := child.maxLength()
+			if l < 0 {
+				return -1
+			}
+			if l > max {
+				max = l
+			}
+		}
+		return max
+
+	case ntConcatenate:
+		sum := 0
+		for _, child := range n.children {
+			l := child.maxLength()
+			if l < 0 || sum+l >= math.MaxInt32 {
+				return -1
+			}
+			sum += l
+		}
+		return sum
+
+	case ntLoop, ntLazyloop:
+		l := n.children[0].maxLength()
+		if l == 0 {
+			return 0
+		}
+		if l < 0 || n.n == math.MaxInt32 || n.n >= math.MaxInt32/l {
+			return -1
+		}
+		return l * n.n
+
+	case ntCapture, ntGroup, ntGreedy:
+		return n.children[0].maxLength()
+	}
+
+	return -1
+}
+
+// maxLookbehind returns how many runes before the position where the node
+// starts matching may be examined, or -1 if there is no bound.
+func (n *regexNode) maxLookbehind() int {
+	switch n.t {
+	case ntBol, ntBoundary, ntNonboundary, ntECMABoundary, ntNonECMABoundary:
+		// these peek at the previous char
+		return 1
+	}
+
+	max := 0
+	for _, child := range n.children {
+		l := child.maxLookbehind()
+		if l < 0 {
+			return -1
+		}
+		if l > max {
+			max = l
+		}
+	}
+
+	if (n.t == ntRequire || n.t == ntPrevent) && n.options&RightToLeft != 0 {
+		// a lookbehind scans back over its content, and anything nested
+		// inside it can look further back from there
+		l := n.children[0].maxLength()
+		if l < 0 {
+			return -1
+		}
+		max += l
+	}
+
+	return max
+}
@@ -6,7 +6,9 @@ import (
 	"os"
 	"sort"
 	"strconv"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 type RegexOptions int32
@@ -105,6 +107,8 @@ const (
 	ErrUnterminatedBracket        = "unterminated [] set"
 	ErrSubtractionMustBeLast      = "a subtraction must be the last element in a character class"
 	ErrReversedCharRange          = "[x-y] range in reverse order"
+	ErrMalformedGroupRef          = "malformed \\g<...> group reference"
+	ErrOctalOutOfRange            = "octal escape value \\%v outside of range 0-0o377"
 )
 
 func (e ErrorCode) String() string {
@@ -138,6 +142,8 @@ type parser struct {
 	options         RegexOptions
 	optionsStack    []RegexOptions
 	ignoreNextParen bool
+
+	replaceOptions ReplaceOptions // syntax of a replacement pattern
 }
 
 const (
@@ -170,6 +176,7 @@ func Parse(re string, op RegexOptions) (*RegexTree, error) {
 		captop:     p.captop,
 		Capnames:   p.capnames,
 		Caplist:    p.capnamelist,
+		Caporder:   p.captureOrder(),
 		options:    op,
 	}
 
@@ -290,6 +297,22 @@ func (p *parser) assignNameSlots() {
 	}
 }
 
+// captureOrder returns the capture slots sorted by their position in the pattern
+func (p *parser) captureOrder() []int {
+	order := make([]int, 0, len(p.caps))
+	for k := range p.caps {
+		order = append(order, k)
+	}
+	sort.Slice(order, func(i, j int) bool {
+		pi, pj := p.caps[order[i]], p.caps[order[j]]
+		if pi != pj {
+			return pi < pj
+		}
+		return order[i] < order[j]
+	})
+	return order
+}
+
 func (p *parser) consumeAutocap() int {
 	r := p.autocap
 	p.autocap++
@@ -677,8 +700,18 @@ BreakOuterScan:
  * Simple parsing for replacement patterns
  */
 func (p *parser) scanReplacement() (*regexNode, error) {
+	return p.scanReplacementText("")
+}
+
+/*
+ * Scans literal text and substitutions of a replacement pattern up to its end
+ * or up to one of the stop characters, which is left to be read.  Stop characters
+ * end the branches of conditional substitutions; a backslash escapes them.
+ */
+func (p *parser) scanReplacementText(stops string) (*regexNode, error) {
 	var c, startpos int
 
+	outer := p.concatenation
 	p.concatenation = newRegexNode(ntConcatenate, p.options)
 
 	for {
@@ -689,26 +722,486 @@ func (p *parser) scanReplacement() (*regexNode, error) {
 
 		startpos = p.textpos()
 
-		for c > 0 && p.rightChar(0) != '$' {
+		for c > 0 && !p.isReplacementSpecial(p.rightChar(0), stops) {
 			p.moveRight(1)
 			c--
 		}
 
 		p.addToConcatenate(startpos, p.textpos()-startpos, true)
 
-		if c > 0 {
-			if p.moveRightGetChar() == '$' {
-				n, err := p.scanDollar()
-				if err != nil {
-					return nil, err
+		if c == 0 || strings.ContainsRune(stops, p.rightChar(0)) {
+			break
+		}
+
+		var n *regexNode
+		var err error
+		switch ch := p.moveRightGetChar(); {
+		case ch == '$':
+			n, err = p.scanReplacementDollar()
+		case ch == '(':
+			n, err = p.scanConditionalParen()
+		case stops != "" && p.charsRight() > 0 && strings.ContainsRune(stops+"\\", p.rightChar(0)):
+			n = newRegexNodeCh(ntOne, p.options, p.moveRightGetChar())
+		default:
+			n, err = p.scanReplacementBackslash()
+		}
+		if err != nil {
+			return nil, err
+		}
+		// a nil node is a substitution that's always empty
+		if n != nil {
+			p.addUnitNode(n)
+			p.addConcatenate()
+		}
+	}
+
+	concat := p.concatenation
+	p.concatenation = outer
+	return concat, nil
+}
+
+// True if ch starts an escape or substitution in a replacement pattern, or
+// is one of the stop characters.
+func (p *parser) isReplacementSpecial(ch rune, stops string) bool {
+	if p.useConditionals() && (ch == '$' || ch == '(' || (ch == '\\' && stops != "")) {
+		return true
+	}
+	if strings.ContainsRune(stops, ch) {
+		return true
+	}
+
+	switch p.replaceDialect() {
+	case ReplacePerl:
+		return ch == '$' || ch == '\\'
+	case ReplacePython:
+		return ch == '\\'
+	}
+	return ch == '$' || (ch == '\\' && p.useCaseConversion())
+}
+
+/*
+ * Scans a conditional substitution (?nyes:no) or (?{name}yes:no), following
+ * the '(', where the no branch is optional.  Any other ( is literal.
+ */
+func (p *parser) scanConditionalParen() (*regexNode, error) {
+	if p.charsRight() < 2 || p.rightChar(0) != '?' {
+		return newRegexNodeCh(ntOne, p.options, '('), nil
+	}
+
+	backpos := p.textpos()
+	p.moveRight(1)
+
+	var capnum int
+	var err error
+	ch := p.rightChar(0)
+	switch {
+	case ch >= '0' && ch <= '9':
+		if capnum, err = p.scanDecimal(); err != nil {
+			return nil, err
+		}
+		if !p.isCaptureSlot(capnum) {
+			return nil, p.getErr(ErrUndefinedBackRef, capnum)
+		}
+	case ch == '{':
+		p.moveRight(1)
+		capname := p.scanCapname()
+		if capname == "" || p.charsRight() == 0 || p.moveRightGetChar() != '}' {
+			return nil, p.getErr(ErrInvalidGroupName)
+		}
+		if !p.isCaptureName(capname) {
+			return nil, p.getErr(ErrUndefinedNameRef, capname)
+		}
+		capnum = p.captureSlotFromName(capname)
+	default:
+		p.textto(backpos)
+		return newRegexNodeCh(ntOne, p.options, '('), nil
+	}
+
+	return p.scanConditionalBranches(capnum, ')', ErrMissingParen)
+}
+
+/*
+ * Scans ${n:+yes:no} or ${name:+yes:no}, following the '$', where the no
+ * branch is optional.  Returns nil without moving if it's not a conditional.
+ */
+func (p *parser) scanConditionalDollar() (*regexNode, error) {
+	if p.charsRight() < 2 || p.rightChar(0) != '{' {
+		return nil, nil
+	}
+
+	backpos := p.textpos()
+	p.moveRight(1)
+
+	var capnum int
+	var capname string
+	if ch := p.rightChar(0); ch >= '0' && ch <= '9' {
+		var err error
+		if capnum, err = p.scanDecimal(); err != nil {
+			return nil, err
+		}
+	} else if IsWordChar(ch) {
+		capname = p.scanCapname()
+	} else {
+		p.textto(backpos)
+		return nil, nil
+	}
+
+	if p.charsRight() < 2 || p.rightChar(0) != ':' || p.rightChar(1) != '+' {
+		p.textto(backpos)
+		return nil, nil
+	}
+	if capname != "" {
+		if !p.isCaptureName(capname) {
+			return nil, p.getErr(ErrUndefinedNameRef, capname)
+		}
+		capnum = p.captureSlotFromName(capname)
+	} else if !p.isCaptureSlot(capnum) {
+		return nil, p.getErr(ErrUndefinedBackRef, capnum)
+	}
+	p.moveRight(2)
+
+	return p.scanConditionalBranches(capnum, '}', ErrMissingBrace)
+}
+
+// scanConditionalBranches scans the branches of a conditional substitution up to
+// and including the closing character
+func (p *parser) scanConditionalBranches(capnum int, close rune, missing ErrorCode) (*regexNode, error) {
+	yes, err := p.scanReplacementText(":" + string(close))
+	if err != nil {
+		return nil, err
+	}
+	no := newRegexNode(ntConcatenate, p.options)
+	if p.charsRight() > 0 && p.rightChar(0) == ':' {
+		p.moveRight(1)
+		if no, err = p.scanReplacementText(string(close)); err != nil {
+			return nil, err
+		}
+	}
+	if p.charsRight() == 0 || p.moveRightGetChar() != close {
+		return nil, p.getErr(missing)
+	}
+
+	n := newRegexNodeM(ntTestref, p.options, capnum)
+	n.children = []*regexNode{yes, no}
+	return n, nil
+}
+
+/*
+ * Scans $ patterns recognized within replacement patterns, following
+ * the '$', in the syntax of the dialect
+ */
+func (p *parser) scanReplacementDollar() (*regexNode, error) {
+	if p.useConditionals() {
+		if n, err := p.scanConditionalDollar(); n != nil || err != nil {
+			return n, err
+		}
+	}
+
+	switch p.replaceDialect() {
+	case ReplacePython:
+		return newRegexNodeCh(ntOne, p.options, '$'), nil
+	case ReplaceJavaScript:
+		return p.scanDollarJS(), nil
+	case ReplacePerl:
+		return p.scanDollarPerl()
+	}
+	return p.scanDollar()
+}
+
+/*
+ * Scans \ escapes recognized within replacement patterns, following
+ * the '\': the case conversions \U \L \u \l \E and the escapes of the dialect
+ */
+func (p *parser) scanReplacementBackslash() (*regexNode, error) {
+	if p.charsRight() == 0 {
+		if p.replaceDialect() == ReplacePython {
+			return nil, p.getErr(ErrIllegalEndEscape)
+		}
+		return newRegexNodeCh(ntOne, p.options, '\\'), nil
+	}
+
+	if p.useCaseConversion() {
+		capnum := 1
+
+		switch p.rightChar(0) {
+		case 'U':
+			capnum = replaceUpperCase
+		case 'L':
+			capnum = replaceLowerCase
+		case 'u':
+			capnum = replaceTitleNext
+		case 'l':
+			capnum = replaceLowerNext
+		case 'E':
+			capnum = replaceEndCase
+		}
+
+		if capnum != 1 {
+			p.moveRight(1)
+			return newRegexNodeM(ntRef, p.options, capnum), nil
+		}
+	}
+
+	switch p.replaceDialect() {
+	case ReplacePerl:
+		return p.scanBackslashPerl(), nil
+	case ReplacePython:
+		return p.scanBackslashPython()
+	}
+
+	if p.rightChar(0) == '\\' {
+		p.moveRight(1)
+		return newRegexNodeCh(ntOne, p.options, '\\'), nil
+	}
+
+	// unrecognized \: literalize
+	return newRegexNodeCh(ntOne, p.options, '\\'), nil
+}
+
+/*
+ * Scans $ patterns of JavaScript's String.prototype.replace: $$ $& $` $'
+ * $n $nn and $<name>.  $nn is a group if it's defined and otherwise $n is
+ * followed by a digit.  Undefined numbers are literal, as is $<name> when the
+ * pattern has no named groups; an undefined name is an empty substitution.
+ */
+func (p *parser) scanDollarJS() *regexNode {
+	if p.charsRight() == 0 {
+		return newRegexNodeCh(ntOne, p.options, '$')
+	}
+
+	ch := p.rightChar(0)
+	switch {
+	case ch == '$':
+		p.moveRight(1)
+		return newRegexNodeCh(ntOne, p.options, '$')
+	case ch == '&':
+		p.moveRight(1)
+		return newRegexNodeM(ntRef, p.options, 0)
+	case ch == '`':
+		p.moveRight(1)
+		return newRegexNodeM(ntRef, p.options, replaceLeftPortion)
+	case ch == '\'':
+		p.moveRight(1)
+		return newRegexNodeM(ntRef, p.options, replaceRightPortion)
+	case ch >= '0' && ch <= '9':
+		capnum := int(ch - '0')
+		if p.charsRight() > 1 {
+			if d := p.rightChar(1); d >= '0' && d <= '9' {
+				if two := capnum*10 + int(d-'0'); two > 0 && p.isCaptureSlot(two) {
+					p.moveRight(2)
+					return newRegexNodeM(ntRef, p.options, two)
 				}
-				p.addUnitNode(n)
 			}
-			p.addConcatenate()
 		}
+		if capnum > 0 && p.isCaptureSlot(capnum) {
+			p.moveRight(1)
+			return newRegexNodeM(ntRef, p.options, capnum)
+		}
+	case ch == '<' && p.hasCaptureNames():
+		for i := 1; i < p.charsRight(); i++ {
+			if p.rightChar(i) == '>' {
+				capname := string(p.pattern[p.textpos()+1 : p.textpos()+i])
+				p.moveRight(i + 1)
+				if p.isNamedCapture(capname) {
+					return newRegexNodeM(ntRef, p.options, p.captureSlotFromName(capname))
+				}
+				return nil
+			}
+		}
+	}
+
+	// unrecognized $: literalize
+	return newRegexNodeCh(ntOne, p.options, '$')
+}
+
+/*
+ * Scans $ patterns of Perl's s///: $n ${n} $& $` $' $+ and $+{name}.  $n takes
+ * all the digits that follow.  Undefined groups are empty substitutions.
+ */
+func (p *parser) scanDollarPerl() (*regexNode, error) {
+	if p.charsRight() == 0 {
+		return newRegexNodeCh(ntOne, p.options, '$'), nil
+	}
+
+	ch := p.rightChar(0)
+	switch {
+	case ch >= '1' && ch <= '9':
+		capnum, err := p.scanDecimal()
+		if err != nil {
+			return nil, err
+		}
+		return p.refIfCaptureSlot(capnum), nil
+	case ch == '{':
+		backpos := p.textpos()
+		p.moveRight(1)
+		if p.charsRight() > 0 && p.rightChar(0) >= '1' && p.rightChar(0) <= '9' {
+			capnum, err := p.scanDecimal()
+			if err != nil {
+				return nil, err
+			}
+			if p.charsRight() > 0 && p.moveRightGetChar() == '}' {
+				return p.refIfCaptureSlot(capnum), nil
+			}
+		}
+		p.textto(backpos)
+	case ch == '&':
+		p.moveRight(1)
+		return newRegexNodeM(ntRef, p.options, 0), nil
+	case ch == '`':
+		p.moveRight(1)
+		return newRegexNodeM(ntRef, p.options, replaceLeftPortion), nil
+	case ch == '\'':
+		p.moveRight(1)
+		return newRegexNodeM(ntRef, p.options, replaceRightPortion), nil
+	case ch == '+':
+		p.moveRight(1)
+		if p.charsRight() > 1 && p.rightChar(0) == '{' {
+			backpos := p.textpos()
+			p.moveRight(1)
+			capname := p.scanCapname()
+			if capname != "" && p.charsRight() > 0 && p.moveRightGetChar() == '}' {
+				if p.isNamedCapture(capname) {
+					return newRegexNodeM(ntRef, p.options, p.captureSlotFromName(capname)), nil
+				}
+				return nil, nil
+			}
+			p.textto(backpos)
+		}
+		return newRegexNodeM(ntRef, p.options, replaceLastMatchedGroup), nil
 	}
 
-	return p.concatenation, nil
+	// unrecognized $: literalize
+	return newRegexNodeCh(ntOne, p.options, '$'), nil
+}
+
+/*
+ * Scans \ escapes of Perl's s///: \1 to \9 not followed by a digit refer to
+ * groups, other digits start octal escapes of up to three digits and \t \n \r \f
+ * \a \e are control characters.  Any other escaped character stands for itself.
+ */
+func (p *parser) scanBackslashPerl() *regexNode {
+	ch := p.moveRightGetChar()
+	switch {
+	case ch >= '1' && ch <= '9' && (p.charsRight() == 0 || p.rightChar(0) < '0' || p.rightChar(0) > '9'):
+		return p.refIfCaptureSlot(int(ch - '0'))
+	case isOctal(ch):
+		val := int(ch - '0')
+		for i := 0; i < 2 && p.charsRight() > 0 && isOctal(p.rightChar(0)); i++ {
+			val = val*8 + int(p.moveRightGetChar()-'0')
+		}
+		ch = rune(val)
+	case ch == 't':
+		ch = '\t'
+	case ch == 'n':
+		ch = '\n'
+	case ch == 'r':
+		ch = '\r'
+	case ch == 'f':
+		ch = '\f'
+	case ch == 'a':
+		ch = '\a'
+	case ch == 'e':
+		ch = '\x1b'
+	}
+	return newRegexNodeCh(ntOne, p.options, ch)
+}
+
+/*
+ * Scans \ escapes of Python's re.sub: \g<name> \g<n> \n and \nn refer to groups,
+ * \0 and three octal digits are octal escapes and \a \b \f \n \r \t \v \\ are
+ * the usual escapes.  References to undefined groups and other escaped ASCII
+ * letters are errors; other escaped characters are kept with the backslash.
+ */
+func (p *parser) scanBackslashPython() (*regexNode, error) {
+	ch := p.moveRightGetChar()
+	switch {
+	case ch == 'g':
+		if p.charsRight() == 0 || p.moveRightGetChar() != '<' {
+			return nil, p.getErr(ErrMalformedGroupRef)
+		}
+		startpos := p.textpos()
+		for p.charsRight() > 0 && p.rightChar(0) != '>' {
+			p.moveRight(1)
+		}
+		if p.charsRight() == 0 || p.textpos() == startpos {
+			return nil, p.getErr(ErrMalformedGroupRef)
+		}
+		capname := string(p.pattern[startpos:p.textpos()])
+		p.moveRight(1)
+
+		if capnum, err := strconv.Atoi(capname); err == nil && capname[0] != '+' && capname[0] != '-' {
+			if !p.isCaptureSlot(capnum) {
+				return nil, p.getErr(ErrUndefinedBackRef, capnum)
+			}
+			return newRegexNodeM(ntRef, p.options, capnum), nil
+		}
+		if !p.isNamedCapture(capname) {
+			return nil, p.getErr(ErrUndefinedNameRef, capname)
+		}
+		return newRegexNodeM(ntRef, p.options, p.captureSlotFromName(capname)), nil
+
+	case ch == '0':
+		// up to two more octal digits
+		val := 0
+		for i := 0; i < 2 && p.charsRight() > 0 && isOctal(p.rightChar(0)); i++ {
+			val = val*8 + int(p.moveRightGetChar()-'0')
+		}
+		return newRegexNodeCh(ntOne, p.options, rune(val)), nil
+
+	case ch >= '1' && ch <= '9':
+		capnum := int(ch - '0')
+		if p.charsRight() > 0 && p.rightChar(0) >= '0' && p.rightChar(0) <= '9' {
+			d := p.moveRightGetChar()
+			if isOctal(ch) && isOctal(d) && p.charsRight() > 0 && isOctal(p.rightChar(0)) {
+				val := int(ch-'0')*64 + int(d-'0')*8 + int(p.moveRightGetChar()-'0')
+				if val > 0377 {
+					return nil, p.getErr(ErrOctalOutOfRange, string(p.pattern[p.textpos()-3:p.textpos()]))
+				}
+				return newRegexNodeCh(ntOne, p.options, rune(val)), nil
+			}
+			capnum = capnum*10 + int(d-'0')
+		}
+		if !p.isCaptureSlot(capnum) {
+			return nil, p.getErr(ErrUndefinedBackRef, capnum)
+		}
+		return newRegexNodeM(ntRef, p.options, capnum), nil
+
+	case ch == 'a':
+		ch = '\a'
+	case ch == 'b':
+		ch = '\b'
+	case ch == 'f':
+		ch = '\f'
+	case ch == 'n':
+		ch = '\n'
+	case ch == 'r':
+		ch = '\r'
+	case ch == 't':
+		ch = '\t'
+	case ch == 'v':
+		ch = '\v'
+	case ch == '\\':
+	case ch < utf8.RuneSelf && unicode.IsLetter(ch):
+		return nil, p.getErr(ErrUnrecognizedEscape, string(ch))
+	default:
+		// keep the backslash
+		p.moveLeft()
+		return newRegexNodeCh(ntOne, p.options, '\\'), nil
+	}
+	return newRegexNodeCh(ntOne, p.options, ch), nil
+}
+
+// refIfCaptureSlot returns a reference to the group, or nil for an empty
+// substitution if there's no such group
+func (p *parser) refIfCaptureSlot(capnum int) *regexNode {
+	if p.isCaptureSlot(capnum) {
+		return newRegexNodeM(ntRef, p.options, capnum)
+	}
+	return nil
+}
+
+func isOctal(ch rune) bool {
+	return ch >= '0' && ch <= '7'
 }
 
 /*
@@ -1931,6 +2424,30 @@ func (p *parser) isCaptureName(capname string) bool {
 	return ok
 }
 
+// True if the pattern has a group named other than by its number
+func (p *parser) hasCaptureNames() bool {
+	for capname := range p.capnames {
+		if !isDecimal(capname) {
+			return true
+		}
+	}
+	return false
+}
+
+// True if capname is the name of a group not named by its number
+func (p *parser) isNamedCapture(capname string) bool {
+	return !isDecimal(capname) && p.isCaptureName(capname)
+}
+
+func isDecimal(s string) bool {
+	for _, ch := range s {
+		if ch < '0' || ch > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
 // option shortcuts
 
 // True if N option disabling '(' autocapture is on.
@@ -1968,6 +2485,27 @@ func (p *parser) useRE2() bool {
 	return (p.options & RE2) != 0
 }
 
+// True if \U \L \u \l \E change case in a replacement pattern.
+func (p *parser) useCaseConversion() bool {
+	return (p.replaceOptions&ReplaceCaseConversion) != 0 || p.replaceDialect() == ReplacePerl
+}
+
+// True if a replacement pattern can have conditional substitutions.
+func (p *parser) useConditionals() bool {
+	return (p.replaceOptions & ReplaceConditionals) != 0
+}
+
+// The dialect of a replacement pattern, JavaScript by default for ECMAScript.
+func (p *parser) replaceDialect() ReplaceOptions {
+	if d := p.replaceOptions & replaceDialects; d != 0 {
+		return d
+	}
+	if p.useOptionE() {
+		return ReplaceJavaScript
+	}
+	return ReplaceDotNet
+}
+
 // True if options stack is empty.
 func (p *parser) emptyOptionsStack() bool {
 	return len(p.optionsStack) == 0
@@ -607,6 +607,21 @@ func (b *BmPrefix) String() string {
 	return string(b.pattern)
 }
 
+// Len returns the number of runes in the prefix
+func (b *BmPrefix) Len() int {
+	return len(b.pattern)
+}
+
+// Pattern returns the prefix, lower cased when it's case-insensitive
+func (b *BmPrefix) Pattern() []rune {
+	return b.pattern
+}
+
+// CaseInsensitive returns true if text must be lower cased before comparing it to the prefix
+func (b *BmPrefix) CaseInsensitive() bool {
+	return b.caseInsensitive
+}
+
 // Dump returns the contents of the filter as a human readable string
 func (b *BmPrefix) Dump(indent string) string {
 	buf := &bytes.Buffer{}
@@ -760,6 +775,25 @@ func (b *BmPrefix) IsMatch(text []rune, index, beglimit, endlimit int) bool {
 	}
 }
 
+// IsPartialMatch returns true if the left-to-right text from index up to endlimit is too
+// short to hold the prefix but matches as much of it as there is, so more text could complete it
+func (b *BmPrefix) IsPartialMatch(text []rune, index, endlimit int) bool {
+	if b.rightToLeft || index < 0 || endlimit-index >= len(b.pattern) {
+		return false
+	}
+
+	for i := 0; index+i < endlimit; i++ {
+		ch := text[index+i]
+		if b.caseInsensitive {
+			ch = unicode.ToLower(ch)
+		}
+		if ch != b.pattern[i] {
+			return false
+		}
+	}
+	return true
+}
+
 func (b *BmPrefix) matchPattern(text []rune, index int) bool {
 	if len(text)-index < len(b.pattern) {
 		return false
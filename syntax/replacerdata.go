@@ -11,25 +11,62 @@ type ReplacerData struct {
 	Rules   []int
 }
 
+// ReplaceOptions select the syntax of a replacement pattern
+type ReplaceOptions int32
+
 const (
-	replaceSpecials     = 4
+	ReplaceCaseConversion ReplaceOptions = 0x0001 // \U \L \u \l and \E change the case of what follows
+	ReplaceConditionals   ReplaceOptions = 0x0002 // ${n:+yes:no} and (?nyes:no) depend on whether group n participated
+
+	// dialects, mutually exclusive
+	ReplaceDotNet     ReplaceOptions = 0x0010 // $n ${n} ${name} $& $` $' $+ $_ and $$, as in .NET's Regex.Replace
+	ReplaceJavaScript ReplaceOptions = 0x0020 // $n $nn $<name> $& $` $' and $$, as in String.prototype.replace
+	ReplacePerl       ReplaceOptions = 0x0040 // $n ${n} \n $& $` $' $+ $+{name} and escapes, as in Perl's s///
+	ReplacePython     ReplaceOptions = 0x0080 // \g<name> \g<n> \n \nn and escapes, as in Python's re.sub
+	replaceDialects   ReplaceOptions = 0x00f0
+)
+
+const (
+	replaceSpecials     = 11
 	replaceLeftPortion  = -1
 	replaceRightPortion = -2
 	replaceLastGroup    = -3
 	replaceWholeString  = -4
+	replaceUpperCase    = -5 // \U: uppercase until \E
+	replaceLowerCase    = -6 // \L: lowercase until \E
+	replaceTitleNext    = -7 // \u: titlecase the next character
+	replaceLowerNext    = -8 // \l: lowercase the next character
+	replaceEndCase      = -9 // \E: end case conversion
+
+	replaceLastMatchedGroup = -10 // Perl's $+: the highest numbered group that participated
+	replaceConditional      = -11 // ${n:+yes:no}: followed by n and the rule counts of yes and no
 )
 
 //ErrReplacementError is a general error during parsing the replacement text
 var ErrReplacementError = errors.New("Replacement pattern error.")
 
+// ErrReplaceDialects is returned when ReplaceOptions select more than one dialect
+var ErrReplaceDialects = errors.New("replacement options select more than one dialect")
+
 // NewReplacerData will populate a reusable replacer data struct based on the given replacement string
 // and the capture group data from a regexp
 func NewReplacerData(rep string, caps map[int]int, capsize int, capnames map[string]int, op RegexOptions) (*ReplacerData, error) {
+	return NewReplacerDataOptions(rep, caps, capsize, capnames, op, 0)
+}
+
+// NewReplacerDataOptions is like NewReplacerData with the replacement syntax selected by rop.
+// Without a dialect in rop the .NET syntax is used, or the JavaScript syntax if op has ECMAScript.
+func NewReplacerDataOptions(rep string, caps map[int]int, capsize int, capnames map[string]int, op RegexOptions, rop ReplaceOptions) (*ReplacerData, error) {
+	if d := rop & replaceDialects; d&(d-1) != 0 {
+		return nil, ErrReplaceDialects
+	}
+
 	p := parser{
-		options:  op,
-		caps:     caps,
-		capsize:  capsize,
-		capnames: capnames,
+		options:        op,
+		replaceOptions: rop,
+		caps:           caps,
+		capsize:        capsize,
+		capnames:       capnames,
 	}
 	p.setPattern(rep)
 	concat, err := p.scanReplacement()
@@ -41,47 +78,68 @@ func NewReplacerData(rep string, caps map[int]int, capsize int, capnames map[str
 		panic(ErrReplacementError)
 	}
 
-	sb := &bytes.Buffer{}
-	var (
-		strings []string
-		rules   []int
-	)
+	b := replacerBuilder{caps: caps}
+	b.addNodes(concat.children)
+	b.flush()
+
+	return &ReplacerData{
+		Rep:     rep,
+		Strings: b.strings,
+		Rules:   b.rules,
+	}, nil
+}
+
+// replacerBuilder turns the nodes of a parsed replacement pattern into rules
+type replacerBuilder struct {
+	caps    map[int]int
+	sb      bytes.Buffer
+	strings []string
+	rules   []int
+}
 
-	for _, child := range concat.children {
+func (b *replacerBuilder) addNodes(nodes []*regexNode) {
+	for _, child := range nodes {
 		switch child.t {
 		case ntMulti:
-			child.writeStrToBuf(sb)
+			child.writeStrToBuf(&b.sb)
 
 		case ntOne:
-			sb.WriteRune(child.ch)
+			b.sb.WriteRune(child.ch)
 
 		case ntRef:
-			if sb.Len() > 0 {
-				rules = append(rules, len(strings))
-				strings = append(strings, sb.String())
-				sb.Reset()
-			}
-			slot := child.m
+			b.flush()
+			b.rules = append(b.rules, -replaceSpecials-1-b.slot(child.m))
 
-			if len(caps) > 0 && slot >= 0 {
-				slot = caps[slot]
+		case ntTestref:
+			// a conditional is followed by its group and the lengths of its branches
+			b.flush()
+			b.rules = append(b.rules, -replaceSpecials-1-replaceConditional, b.slot(child.m), 0, 0)
+			at := len(b.rules)
+			for i, branch := range child.children {
+				start := len(b.rules)
+				b.addNodes(branch.children)
+				b.flush()
+				b.rules[at-2+i] = len(b.rules) - start
 			}
 
-			rules = append(rules, -replaceSpecials-1-slot)
-
 		default:
 			panic(ErrReplacementError)
 		}
 	}
+}
 
-	if sb.Len() > 0 {
-		rules = append(rules, len(strings))
-		strings = append(strings, sb.String())
+// flush adds a rule for the pending literal text, if there is any
+func (b *replacerBuilder) flush() {
+	if b.sb.Len() > 0 {
+		b.rules = append(b.rules, len(b.strings))
+		b.strings = append(b.strings, b.sb.String())
+		b.sb.Reset()
 	}
+}
 
-	return &ReplacerData{
-		Rep:     rep,
-		Strings: strings,
-		Rules:   rules,
-	}, nil
+func (b *replacerBuilder) slot(m int) int {
+	if len(b.caps) > 0 && m >= 0 {
+		return b.caps[m]
+	}
+	return m
 }
@@ -14,6 +14,7 @@ type RegexTree struct {
 	captop     int
 	Capnames   map[string]int
 	Caplist    []string
+	Caporder   []int // group numbers in the order their groups open in the pattern
 	options    RegexOptions
 }
 
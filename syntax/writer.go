@@ -38,6 +38,9 @@ type writer struct {
 	count       int
 	trackcount  int
 	caps        map[int]int
+
+	lookaroundStarts []int
+	inLookaround     []bool
 }
 
 const (
@@ -137,6 +140,14 @@ func (w *writer) codeFromTree(tree *RegexTree) (*Code, error) {
 		bmPrefix = nil
 	}
 
+	// sets are final at this point, so precompute their ASCII lookups
+	for _, set := range w.settable {
+		set.buildASCIILookup()
+	}
+	if fcPrefix != nil {
+		fcPrefix.PrefixSet.buildASCIILookup()
+	}
+
 	return &Code{
 		Codes:       w.emitted,
 		Strings:     w.stringtable,
@@ -148,6 +159,10 @@ func (w *writer) codeFromTree(tree *RegexTree) (*Code, error) {
 		BmPrefix:    bmPrefix,
 		Anchors:     getAnchors(tree),
 		RightToLeft: rtl,
+		MaxLength:   tree.root.maxLength(),
+		Lookbehind:  tree.root.maxLookbehind(),
+
+		InLookaround: w.inLookaround,
 	}, nil
 }
 
@@ -291,9 +306,11 @@ func (w *writer) emitFragment(nodetype nodeType, node *regexNode, curIndex int)
 		// NON-BACKTRACKING. It can be commented out with (*)
 		w.emit(Setjump)
 
+		w.startLookaround()
 		w.emit(Setmark)
 
 	case ntRequire | afterChild:
+		w.endLookaround()
 		w.emit(Getmark)
 
 		// NOTE: the following line causes lookahead/lookbehind to be
@@ -302,10 +319,12 @@ func (w *writer) emitFragment(nodetype nodeType, node *regexNode, curIndex int)
 
 	case ntPrevent | beforeChild:
 		w.emit(Setjump)
+		w.startLookaround()
 		w.pushInt(w.curPos())
 		w.emit1(Lazybranch, 0)
 
 	case ntPrevent | afterChild:
+		w.endLookaround()
 		w.emit(Backjump)
 		w.patchJump(w.popInt(), w.curPos())
 		w.emit(Forejump)
@@ -452,6 +471,29 @@ func (w *writer) mapCapnum(capnum int) int {
 	return capnum
 }
 
+// startLookaround notes that a lookaround begins at the current position, right after its Setjump
+func (w *writer) startLookaround() {
+	if !w.counting {
+		w.lookaroundStarts = append(w.lookaroundStarts, w.curPos())
+	}
+}
+
+// endLookaround marks the code emitted since the matching startLookaround
+// as the body of a lookaround
+func (w *writer) endLookaround() {
+	if w.counting {
+		return
+	}
+	if w.inLookaround == nil {
+		w.inLookaround = make([]bool, len(w.emitted))
+	}
+	start := w.lookaroundStarts[len(w.lookaroundStarts)-1]
+	w.lookaroundStarts = w.lookaroundStarts[:len(w.lookaroundStarts)-1]
+	for i := start; i < w.curPos(); i++ {
+		w.inLookaround[i] = true
+	}
+}
+
 // Emits a zero-argument operation. Note that the emit
 // functions all run in two modes: they can emit code, or
 // they can just count the size of the code.